@@ -46,3 +46,11 @@ Create chart name and version as used by the chart label.
 {{- define "cert-manager-alidns-webhook.servingCertificate" -}}
 {{ printf "%s-webhook-tls" (include "cert-manager-alidns-webhook.fullname" .) }}
 {{- end -}}
+
+{{- define "cert-manager-alidns-webhook.routesConfigMap" -}}
+{{- if .Values.zoneRoutes.routes -}}
+{{ printf "%s-routes" (include "cert-manager-alidns-webhook.fullname" .) }}
+{{- else -}}
+{{ .Values.zoneRoutes.configMapName }}
+{{- end -}}
+{{- end -}}
@@ -36,6 +36,7 @@ func TestRunsSuite(t *testing.T) {
 
 	fixture := acmetest.NewFixture(solver,
 		acmetest.SetResolvedZone(zone),
+		acmetest.SetAllowAmbientCredentials(true),
 		acmetest.SetManifestPath("testdata/my-custom-solver"),
 		acmetest.SetDNSServer("223.5.5.5:53"),
 	)
@@ -0,0 +1,70 @@
+package alidns
+
+import (
+	"log/slog"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// challengeGVR 是 cert-manager 的 Challenge 资源
+var challengeGVR = schema.GroupVersionResource{
+	Group:    "acme.cert-manager.io",
+	Version:  "v1",
+	Resource: "challenges",
+}
+
+// watchChallenges 监听所有 namespace 的 Challenge。
+// Challenge 在 CleanUp 之前被删除时（例如删除了进行中的 Certificate），
+// 按 journal 中记录的 challenge 删除 Present 创建的 TXT 记录。
+// 返回的函数报告 informer 是否已完成首次同步
+func (s *Solver) watchChallenges(client dynamic.Interface, stopCh <-chan struct{}) cache.InformerSynced {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, 0)
+	informer := factory.ForResource(challengeGVR).Informer()
+	_, _ = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if challenge, ok := obj.(*unstructured.Unstructured); ok {
+				s.onChallengeDeleted(challenge)
+			}
+		},
+	})
+	factory.Start(stopCh)
+	return informer.HasSynced
+}
+
+// onChallengeDeleted 删除已删除 Challenge 遗留的 TXT 记录，失败时加入重试队列。
+// 按 spec.dnsName 和 spec.key 查找 journal，与 Present 写入时使用的 journalKey 一致；
+// journal 中没有记录说明 CleanUp 已经完成或 challenge 不是由本 webhook 处理的
+func (s *Solver) onChallengeDeleted(challenge *unstructured.Unstructured) {
+	dnsName, _, _ := unstructured.NestedString(challenge.Object, "spec", "dnsName")
+	key, _, _ := unstructured.NestedString(challenge.Object, "spec", "key")
+	if dnsName == "" || key == "" {
+		return
+	}
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+
+	entry, ok := s.journal.get(ctx, challengeKey(dnsName, key))
+	if !ok || entry.Challenge == nil {
+		return
+	}
+
+	slog.Info("Challenge deleted before CleanUp, deleting its TXT record",
+		"challenge", challenge.GetNamespace()+"/"+challenge.GetName(),
+		"dnsName", dnsName,
+		"domain", entry.Domain,
+		"rr", entry.RR,
+		"recordIds", entry.RecordIDs,
+	)
+	ch := entry.Challenge.challengeRequest()
+	if err := s.cleanUp(ctx, ch); err != nil {
+		s.cleanupQueue.add(ch, err)
+	}
+}
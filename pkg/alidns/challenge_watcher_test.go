@@ -0,0 +1,139 @@
+package alidns
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSolver_OnChallengeDeleted(t *testing.T) {
+	// cert-manager 不设置 UID，跟随 CNAME 后 ResolvedFQDN 与 dnsName 不再对应
+	ch := &v1alpha1.ChallengeRequest{
+		DNSName:                 "example.com",
+		ResolvedFQDN:            "_acme-challenge.example.net.",
+		ResolvedZone:            "example.net.",
+		Key:                     "test-key",
+		AllowAmbientCredentials: true,
+	}
+	spec := map[string]interface{}{"dnsName": "example.com", "key": "test-key"}
+
+	tests := []struct {
+		name        string
+		present     bool
+		cleanedUp   bool
+		spec        map[string]interface{}
+		deleteErr   error
+		expectByID  []string
+		expectQueue bool
+	}{
+		{name: "presented challenge is cleaned up", present: true, spec: spec, expectByID: []string{"record-1"}},
+		{name: "challenge already cleaned up", present: true, cleanedUp: true, spec: spec, expectByID: []string{"record-1"}},
+		{name: "unknown challenge is ignored", present: false, spec: spec},
+		{name: "different key is ignored", present: true, spec: map[string]interface{}{"dnsName": "example.com", "key": "other-key"}},
+		{name: "different dns name is ignored", present: true, spec: map[string]interface{}{"dnsName": "www.example.com", "key": "test-key"}},
+		{name: "challenge without spec is ignored", present: true},
+		{name: "failed deletion is queued", present: true, spec: spec, deleteErr: errors.New("throttled"), expectByID: []string{"record-1"}, expectQueue: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var byID []string
+			provider := &MockDNSProvider{
+				ResolveDomainFunc: func(fqdn string) (string, string, error) {
+					return "example.net", "_acme-challenge", nil
+				},
+				AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+					return "record-1", nil
+				},
+				DeleteRecordFunc: func(recordId string) error {
+					byID = append(byID, recordId)
+					return tt.deleteErr
+				},
+				DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+					return tt.deleteErr
+				},
+			}
+			solver := NewSolver(provider)
+
+			if tt.present {
+				require.NoError(t, solver.Present(ch))
+			}
+			if tt.cleanedUp {
+				require.NoError(t, solver.CleanUp(ch))
+			}
+
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			obj.SetNamespace("default")
+			obj.SetName("example-challenge")
+			obj.SetUID("challenge-uid")
+			if tt.spec != nil {
+				obj.Object["spec"] = tt.spec
+			}
+			solver.onChallengeDeleted(obj)
+
+			assert.Equal(t, tt.expectByID, byID)
+			assert.Equal(t, tt.expectQueue, len(solver.cleanupQueue.tasks) > 0)
+		})
+	}
+}
+
+func TestSolver_WatchChallenges(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{
+		DNSName:                 "example.com",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key",
+		AllowAmbientCredentials: true,
+	}
+
+	var mu sync.Mutex
+	var byID []string
+	provider := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "record-1", nil
+		},
+		DeleteRecordFunc: func(recordId string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			byID = append(byID, recordId)
+			return nil
+		},
+	}
+	solver := NewSolver(provider)
+	require.NoError(t, solver.Present(ch))
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("acme.cert-manager.io/v1")
+	obj.SetKind("Challenge")
+	obj.SetNamespace("default")
+	obj.SetName("example-challenge")
+	obj.SetUID("challenge-uid")
+	obj.Object["spec"] = map[string]interface{}{"dnsName": ch.DNSName, "key": ch.Key}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{challengeGVR: "ChallengeList"}, obj)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	synced := solver.watchChallenges(client, stopCh)
+
+	// 等待 informer 同步后删除 Challenge
+	require.True(t, cache.WaitForCacheSync(stopCh, synced))
+	require.NoError(t, client.Resource(challengeGVR).Namespace("default").Delete(t.Context(), "example-challenge", metav1.DeleteOptions{}))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(byID) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
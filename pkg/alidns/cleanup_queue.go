@@ -0,0 +1,340 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// cleanupQueueInterval 是后台检查到期重试任务的间隔
+	cleanupQueueInterval = 30 * time.Second
+	// cleanupMaxAttempts 是后台重试删除的最大次数，超过后放弃并记录错误日志
+	cleanupMaxAttempts = 12
+	// cleanupPersistTimeout 是写入 ConfigMap 的超时时间。
+	// 不使用 CleanUp 的 context，它在删除失败时往往已经超时或被取消
+	cleanupPersistTimeout = 10 * time.Second
+)
+
+// cleanupRetryPolicy 是后台重试删除的退避策略
+var cleanupRetryPolicy = retryPolicy{
+	baseDelay: time.Minute,
+	maxDelay:  time.Hour,
+}
+
+// challengeSnapshot 保存重新执行 CleanUp 所需的 challenge 信息
+type challengeSnapshot struct {
+	UID                     string          `json:"uid,omitempty"`
+	DNSName                 string          `json:"dnsName,omitempty"`
+	ResolvedFQDN            string          `json:"resolvedFQDN"`
+	ResolvedZone            string          `json:"resolvedZone"`
+	Key                     string          `json:"key"`
+	ResourceNamespace       string          `json:"resourceNamespace"`
+	AllowAmbientCredentials bool            `json:"allowAmbientCredentials"`
+	Config                  json.RawMessage `json:"config,omitempty"`
+}
+
+// newChallengeSnapshot 复制 ch 中 CleanUp 需要的字段
+func newChallengeSnapshot(ch *v1alpha1.ChallengeRequest) challengeSnapshot {
+	snapshot := challengeSnapshot{
+		UID:                     string(ch.UID),
+		DNSName:                 ch.DNSName,
+		ResolvedFQDN:            ch.ResolvedFQDN,
+		ResolvedZone:            ch.ResolvedZone,
+		Key:                     ch.Key,
+		ResourceNamespace:       ch.ResourceNamespace,
+		AllowAmbientCredentials: ch.AllowAmbientCredentials,
+	}
+	if ch.Config != nil {
+		snapshot.Config = ch.Config.Raw
+	}
+	return snapshot
+}
+
+// challengeRequest 还原出 CleanUp 使用的 ChallengeRequest
+func (c challengeSnapshot) challengeRequest() *v1alpha1.ChallengeRequest {
+	ch := &v1alpha1.ChallengeRequest{
+		UID:                     types.UID(c.UID),
+		DNSName:                 c.DNSName,
+		ResolvedFQDN:            c.ResolvedFQDN,
+		ResolvedZone:            c.ResolvedZone,
+		Key:                     c.Key,
+		ResourceNamespace:       c.ResourceNamespace,
+		AllowAmbientCredentials: c.AllowAmbientCredentials,
+	}
+	if len(c.Config) > 0 {
+		ch.Config = &extapi.JSON{Raw: c.Config}
+	}
+	return ch
+}
+
+// cleanupTask 是队列中的一次待重试删除
+type cleanupTask struct {
+	challengeSnapshot
+
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError"`
+}
+
+// cleanupQueue 以 journalKey 为 key 保存 CleanUp 失败的记录，由后台任务按退避策略重试删除。
+// 配置了 ConfigMap 时同时持久化，webhook 重启后继续重试。
+// 零值只保存在内存中，nil 时所有操作为空操作
+type cleanupQueue struct {
+	mu    sync.Mutex
+	tasks map[string]cleanupTask
+
+	// store 是持久化使用的 ConfigMap，为 nil 时不持久化
+	store *configMapStore
+	// now 用于测试中替换时钟
+	now func() time.Time
+}
+
+// newConfigMapCleanupQueue 创建持久化到 namespace/name ConfigMap 的队列
+func newConfigMapCleanupQueue(client kubernetes.Interface, namespace, name string) *cleanupQueue {
+	return &cleanupQueue{store: &configMapStore{client: client, namespace: namespace, name: name}}
+}
+
+func (q *cleanupQueue) currentTime() time.Time {
+	if q.now != nil {
+		return q.now()
+	}
+	return time.Now()
+}
+
+// isPermanentCleanupError 判断删除失败是否重试也不会成功，例如配置错误、
+// RAM 权限不足、凭据无效或域名不在账号下，这类错误不进入重试队列
+func isPermanentCleanupError(err error) bool {
+	return errors.Is(err, errInvalidConfig) ||
+		errors.Is(err, ErrPermissionDenied) ||
+		errors.Is(err, ErrInvalidCredentials) ||
+		errors.Is(err, ErrZoneNotFound)
+}
+
+// add 记录一次失败的删除，已在队列中的 challenge 保留原有的重试进度。
+// 永久错误不入队，只记录错误日志
+func (q *cleanupQueue) add(ch *v1alpha1.ChallengeRequest, cause error) {
+	if q == nil {
+		return
+	}
+	key := journalKey(ch)
+
+	if isPermanentCleanupError(cause) {
+		q.removeKey(key)
+		slog.Error("TXT record deletion failed permanently and will not be retried, remove it manually",
+			"fqdn", ch.ResolvedFQDN,
+			"value", ch.Key,
+			"error", cause,
+		)
+		return
+	}
+
+	q.mu.Lock()
+	if q.tasks == nil {
+		q.tasks = map[string]cleanupTask{}
+	}
+	task, ok := q.tasks[key]
+	if !ok {
+		task = cleanupTask{
+			challengeSnapshot: newChallengeSnapshot(ch),
+			NextAttempt:       q.currentTime().Add(cleanupRetryPolicy.delay(1)),
+		}
+	}
+	task.LastError = cause.Error()
+	q.tasks[key] = task
+	cleanupQueuePending.Set(float64(len(q.tasks)))
+	q.mu.Unlock()
+
+	if !ok {
+		slog.Warn("Queued failed TXT record deletion for retry",
+			"fqdn", ch.ResolvedFQDN,
+			"value", ch.Key,
+			"nextAttempt", task.NextAttempt,
+			"error", cause,
+		)
+	}
+	q.persist(key, &task)
+}
+
+// remove 从队列中移除 challenge，例如 cert-manager 重试的 CleanUp 已经成功
+func (q *cleanupQueue) remove(ch *v1alpha1.ChallengeRequest) {
+	if q == nil {
+		return
+	}
+	q.removeKey(journalKey(ch))
+}
+
+func (q *cleanupQueue) removeKey(key string) {
+	q.mu.Lock()
+	_, ok := q.tasks[key]
+	delete(q.tasks, key)
+	cleanupQueuePending.Set(float64(len(q.tasks)))
+	q.mu.Unlock()
+
+	if ok {
+		q.persist(key, nil)
+	}
+}
+
+// due 返回已到重试时间的任务
+func (q *cleanupQueue) due() map[string]cleanupTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.currentTime()
+	tasks := map[string]cleanupTask{}
+	for key, task := range q.tasks {
+		if !now.Before(task.NextAttempt) {
+			tasks[key] = task
+		}
+	}
+	return tasks
+}
+
+// reschedule 记录一次失败的重试，超过 cleanupMaxAttempts 或遇到永久错误时放弃并返回 false
+func (q *cleanupQueue) reschedule(key string, task cleanupTask, cause error) bool {
+	task.Attempts++
+	task.LastError = cause.Error()
+	if task.Attempts >= cleanupMaxAttempts || isPermanentCleanupError(cause) {
+		q.removeKey(key)
+		return false
+	}
+	task.NextAttempt = q.currentTime().Add(cleanupRetryPolicy.delay(task.Attempts + 1))
+
+	q.mu.Lock()
+	if _, ok := q.tasks[key]; !ok {
+		// 重试期间已被成功的 CleanUp 移除
+		q.mu.Unlock()
+		return true
+	}
+	q.tasks[key] = task
+	q.mu.Unlock()
+
+	q.persist(key, &task)
+	return true
+}
+
+// load 从 ConfigMap 恢复队列
+func (q *cleanupQueue) load(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+	data, err := q.store.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.tasks == nil {
+		q.tasks = map[string]cleanupTask{}
+	}
+	for key, raw := range data {
+		var task cleanupTask
+		if err := json.Unmarshal([]byte(raw), &task); err != nil {
+			slog.Warn("Ignoring invalid cleanup queue entry", "key", key, "error", err)
+			continue
+		}
+		q.tasks[key] = task
+	}
+	cleanupQueuePending.Set(float64(len(q.tasks)))
+	return nil
+}
+
+// persist 将任务写入 ConfigMap，task 为 nil 时删除，失败只记录日志
+func (q *cleanupQueue) persist(key string, task *cleanupTask) {
+	if q.store == nil {
+		return
+	}
+
+	var data string
+	if task != nil {
+		raw, err := json.Marshal(task)
+		if err != nil {
+			slog.Warn("Failed to encode cleanup queue entry", "key", key, "error", err)
+			return
+		}
+		data = string(raw)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupPersistTimeout)
+	defer cancel()
+	err := q.store.update(ctx, func(stored map[string]string) bool {
+		if task == nil {
+			if _, ok := stored[key]; !ok {
+				return false
+			}
+			delete(stored, key)
+			return true
+		}
+		stored[key] = data
+		return true
+	})
+	if err != nil {
+		slog.Warn("Failed to persist cleanup queue", "key", key, "error", err)
+	}
+}
+
+// runCleanupQueue 定期重试队列中到期的删除任务，直到 stopCh 关闭
+func (s *Solver) runCleanupQueue(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(cleanupQueueInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			slog.Info("Stopping cleanup queue worker")
+			return
+		case <-ticker.C:
+			s.retryCleanups(stopCh)
+		}
+	}
+}
+
+// retryCleanups 重试一轮到期的删除任务
+func (s *Solver) retryCleanups(stopCh <-chan struct{}) {
+	for key, task := range s.cleanupQueue.due() {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		ctx, cancel := s.operationContext()
+		err := s.cleanUp(ctx, task.challengeRequest())
+		switch {
+		case err == nil:
+			s.cleanupQueue.removeKey(key)
+			cleanupQueueRetries.WithLabelValues("success").Inc()
+			slog.Info("Deleted TXT record from cleanup queue",
+				"fqdn", task.ResolvedFQDN,
+				"value", task.Key,
+				"attempts", task.Attempts+1,
+			)
+		case s.cleanupQueue.reschedule(key, task, err):
+			cleanupQueueRetries.WithLabelValues("failure").Inc()
+			slog.Warn("Retry of TXT record deletion failed",
+				"fqdn", task.ResolvedFQDN,
+				"value", task.Key,
+				"attempts", task.Attempts+1,
+				"error", err,
+			)
+		default:
+			cleanupQueueRetries.WithLabelValues("dropped").Inc()
+			slog.Error("Giving up deleting TXT record, remove it manually",
+				"fqdn", task.ResolvedFQDN,
+				"value", task.Key,
+				"attempts", task.Attempts+1,
+				"error", err,
+			)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,225 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newCleanupChallenge() *v1alpha1.ChallengeRequest {
+	return &v1alpha1.ChallengeRequest{
+		UID:                     "challenge-uid",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key",
+		ResourceNamespace:       "default",
+		AllowAmbientCredentials: true,
+		Config:                  &extapi.JSON{Raw: []byte(`{}`)},
+	}
+}
+
+func TestCleanupQueue_Key(t *testing.T) {
+	queue := &cleanupQueue{}
+	ch := newCleanupChallenge()
+
+	// 与 journal 一样按记录名和值区分，cert-manager 不会填充 UID
+	queue.add(ch, errors.New("delete failed"))
+	require.Contains(t, queue.tasks, journalKey(ch))
+
+	retried := newCleanupChallenge()
+	retried.UID = ""
+	queue.add(retried, errors.New("delete failed"))
+	assert.Len(t, queue.tasks, 1)
+
+	other := newCleanupChallenge()
+	other.Key = "other-key"
+	queue.add(other, errors.New("delete failed"))
+	assert.Len(t, queue.tasks, 2)
+}
+
+func TestCleanupQueue_Schedule(t *testing.T) {
+	now := time.Now()
+	queue := &cleanupQueue{now: func() time.Time { return now }}
+	ch := newCleanupChallenge()
+
+	queue.add(ch, errors.New("delete failed"))
+	assert.Empty(t, queue.due(), "首次重试需要等待退避时间")
+
+	now = now.Add(cleanupRetryPolicy.maxDelay)
+	due := queue.due()
+	require.Len(t, due, 1)
+	task := due[journalKey(ch)]
+	assert.Equal(t, "delete failed", task.LastError)
+	assert.Equal(t, ch, task.challengeRequest())
+
+	// 失败后重新排期
+	require.True(t, queue.reschedule(journalKey(ch), task, errors.New("still failing")))
+	assert.Empty(t, queue.due())
+	assert.Equal(t, 1, queue.tasks[journalKey(ch)].Attempts)
+
+	// 达到最大次数后放弃
+	task = queue.tasks[journalKey(ch)]
+	task.Attempts = cleanupMaxAttempts - 1
+	assert.False(t, queue.reschedule(journalKey(ch), task, errors.New("still failing")))
+	assert.Empty(t, queue.tasks)
+
+	// CleanUp 成功后移除
+	queue.add(ch, errors.New("delete failed"))
+	queue.remove(ch)
+	assert.Empty(t, queue.tasks)
+
+	// nil 队列为空操作
+	var disabled *cleanupQueue
+	disabled.add(ch, errors.New("delete failed"))
+	disabled.remove(ch)
+}
+
+func TestCleanupQueue_ConfigMap(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	queue := newConfigMapCleanupQueue(client, "cert-manager", "alidns-cleanup-queue")
+	ch := newCleanupChallenge()
+
+	queue.add(ch, errors.New("delete failed"))
+
+	cm, err := client.CoreV1().ConfigMaps("cert-manager").Get(ctx, "alidns-cleanup-queue", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, cm.Data, journalKey(ch))
+	var stored cleanupTask
+	require.NoError(t, json.Unmarshal([]byte(cm.Data[journalKey(ch)]), &stored))
+	assert.Equal(t, "test-key", stored.Key)
+
+	// 重启后从 ConfigMap 恢复
+	restarted := newConfigMapCleanupQueue(client, "cert-manager", "alidns-cleanup-queue")
+	require.NoError(t, restarted.load(ctx))
+	require.Contains(t, restarted.tasks, journalKey(ch))
+	assert.Equal(t, ch, restarted.tasks[journalKey(ch)].challengeRequest())
+
+	restarted.remove(ch)
+	cm, err = client.CoreV1().ConfigMaps("cert-manager").Get(ctx, "alidns-cleanup-queue", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, cm.Data, journalKey(ch))
+}
+
+func TestSolver_CleanUp_QueuesFailures(t *testing.T) {
+	ch := newCleanupChallenge()
+	deleteErr := errors.New("service unavailable")
+	provider := &MockDNSProvider{
+		ResolveDomainFunc: func(fqdn string) (string, string, error) {
+			return "example.com", "_acme-challenge", nil
+		},
+		DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+			return deleteErr
+		},
+	}
+	solver := NewSolver(provider)
+
+	require.Error(t, solver.CleanUp(ch))
+	require.Contains(t, solver.cleanupQueue.tasks, journalKey(ch))
+
+	// cert-manager 重试的 CleanUp 成功后移出队列
+	deleteErr = nil
+	require.NoError(t, solver.CleanUp(ch))
+	assert.Empty(t, solver.cleanupQueue.tasks)
+}
+
+func TestSolver_CleanUp_SkipsPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		allowAmbient bool
+		deleteErr    error
+	}{
+		{name: "invalid config", config: `{"backend":"unknown"}`, allowAmbient: true},
+		{name: "ambient not allowed", config: `{}`},
+		{name: "permission denied", config: `{}`, allowAmbient: true, deleteErr: &APIError{Kind: ErrPermissionDenied, Code: "Forbidden.RAM"}},
+		{name: "invalid credentials", config: `{}`, allowAmbient: true, deleteErr: &APIError{Kind: ErrInvalidCredentials, Code: "InvalidAccessKeyId.NotFound"}},
+		{name: "zone not found", config: `{}`, allowAmbient: true, deleteErr: &APIError{Kind: ErrZoneNotFound, Code: "InvalidDomainName.NoExist"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &MockDNSProvider{
+				ResolveDomainFunc: func(fqdn string) (string, string, error) {
+					return "example.com", "_acme-challenge", nil
+				},
+				DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+					return tt.deleteErr
+				},
+			}
+			solver := NewSolver(provider)
+			ch := newCleanupChallenge()
+			ch.Config = &extapi.JSON{Raw: []byte(tt.config)}
+			ch.AllowAmbientCredentials = tt.allowAmbient
+
+			// 已因临时错误入队的记录遇到永久错误后也不再重试
+			solver.cleanupQueue.add(ch, errors.New("service unavailable"))
+			require.Contains(t, solver.cleanupQueue.tasks, journalKey(ch))
+
+			err := solver.CleanUp(ch)
+			require.Error(t, err)
+			assert.True(t, isPermanentCleanupError(err))
+			assert.Empty(t, solver.cleanupQueue.tasks)
+		})
+	}
+}
+
+func TestSolver_RetryCleanups(t *testing.T) {
+	tests := []struct {
+		name          string
+		attempts      int
+		deleteErr     error
+		expectResult  string
+		expectPending bool
+	}{
+		{name: "success", deleteErr: nil, expectResult: "success"},
+		{name: "failure", deleteErr: errors.New("still failing"), expectResult: "failure", expectPending: true},
+		{name: "dropped", attempts: cleanupMaxAttempts - 1, deleteErr: errors.New("still failing"), expectResult: "dropped"},
+		{name: "permanent", deleteErr: &APIError{Kind: ErrPermissionDenied, Code: "Forbidden.RAM"}, expectResult: "dropped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			deletes := 0
+			provider := &MockDNSProvider{
+				ResolveDomainFunc: func(fqdn string) (string, string, error) {
+					return "example.com", "_acme-challenge", nil
+				},
+				DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+					deletes++
+					return tt.deleteErr
+				},
+			}
+			solver := NewSolver(provider)
+			solver.cleanupQueue.now = func() time.Time { return now }
+
+			ch := newCleanupChallenge()
+			solver.cleanupQueue.add(ch, errors.New("delete failed"))
+			task := solver.cleanupQueue.tasks[journalKey(ch)]
+			task.Attempts = tt.attempts
+			solver.cleanupQueue.tasks[journalKey(ch)] = task
+
+			// 未到重试时间时不删除
+			solver.retryCleanups(make(chan struct{}))
+			assert.Equal(t, 0, deletes)
+
+			before := testutil.ToFloat64(cleanupQueueRetries.WithLabelValues(tt.expectResult))
+			now = now.Add(cleanupRetryPolicy.maxDelay)
+			solver.retryCleanups(make(chan struct{}))
+			assert.Equal(t, 1, deletes)
+			assert.Equal(t, before+1, testutil.ToFloat64(cleanupQueueRetries.WithLabelValues(tt.expectResult)))
+			assert.Equal(t, tt.expectPending, len(solver.cleanupQueue.tasks) > 0)
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package alidns
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
 	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
@@ -17,62 +21,126 @@ const (
 	defaultEndpoint = "alidns.aliyuncs.com"
 	pageSizeRequest = 100
 	recordType      = "TXT"
+	// defaultLine 是 AliDNS 的默认解析线路
+	defaultLine = "default"
+	// recordStatusDisable 是 DescribeSubDomainRecords 返回的已暂停记录的状态
+	recordStatusDisable = "DISABLE"
+	// recordStatusEnable 是 SetDomainRecordStatus 启用记录时使用的状态
+	recordStatusEnable = "Enable"
 )
 
+// errRecordLocked 表示已存在的匹配记录被锁定，webhook 无法修改或删除
+var errRecordLocked = errors.New("record is locked")
+
 // AliDNSClient 定义阿里云 DNS 客户端接口
 type AliDNSClient interface {
 	AddDomainRecordWithOptions(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error)
 	DeleteDomainRecordWithOptions(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error)
 	DescribeDomainRecordsWithOptions(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error)
+	DescribeDomainsWithOptions(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error)
+	DescribeDomainInfoWithOptions(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error)
+	DescribeSubDomainRecordsWithOptions(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error)
+	SetDomainRecordStatusWithOptions(request *alidns.SetDomainRecordStatusRequest, runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error)
+	UpdateDomainRecordRemarkWithOptions(request *alidns.UpdateDomainRecordRemarkRequest, runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error)
 }
 
+// DNSProvider 的所有方法都会在 ctx 取消或超时后尽快返回，
+// 每次 API 调用的建连和读超时由 ctx 的截止时间决定
 type DNSProvider interface {
-	AddTXTRecord(domain, rr, value string) (string, error)
-	DeleteRecordsByKey(domain, rr, value string) error
+	// ResolveDomain 返回账号中覆盖 fqdn 的域名及主机记录
+	ResolveDomain(ctx context.Context, fqdn string) (domain, rr string, err error)
+	// AddTXTRecord 添加 TXT 记录，记录已存在时返回现有记录的 ID，
+	// 已存在的记录被暂停时重新启用，被锁定时返回错误
+	AddTXTRecord(ctx context.Context, domain, rr, value string, opts RecordOptions) (string, error)
+	// DeleteRecord 按 AddTXTRecord 返回的记录 ID 删除记录
+	DeleteRecord(ctx context.Context, recordId string) error
+	// DeleteRecordsByKey 删除 rr 下值为 value 的记录，
+	// owner 不为空时只删除备注标记中所有者为 owner 的记录
+	DeleteRecordsByKey(ctx context.Context, domain, rr, value, owner string) error
+	// SetRecordRemark 设置记录的备注，用于标记记录的所有者
+	SetRecordRemark(ctx context.Context, recordId, remark string) error
+}
+
+// RecordOptions 是添加记录时的可选参数
+type RecordOptions struct {
+	// TTL 是记录的 TTL（秒），为 0 时使用域名版本允许的最小 TTL
+	TTL int
+	// Line 是记录的解析线路，例如 telecom、overseas，为空时使用默认线路
+	Line string
 }
 
 // dnsProvider 是 AliDNS 的客户端封装
 type dnsProvider struct {
 	client AliDNSClient
+	zones  *zoneResolver
+	// minTTLs 缓存各域名版本允许的最小 TTL
+	minTTLs *minTTLCache
 }
 
 // DNSProvider defines the interface for DNS operations
 
-// NewDNSProvider 创建一个新的 AliDNS 客户端
+// NewDNSProvider 使用默认凭据链创建一个新的 AliDNS 客户端
 func NewDNSProvider() (DNSProvider, error) {
-	credential, err := credential.NewCredential(nil)
+	cred, err := credential.NewCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewDNSProviderWithCredential(cred, "")
+}
+
+// NewDNSProviderWithCredential 使用指定凭据创建一个新的 AliDNS 客户端，
+// regionID 为空时使用环境变量 ALIBABA_CLOUD_REGION_ID
+func NewDNSProviderWithCredential(cred credential.Credential, regionID string) (DNSProvider, error) {
+	client, err := newAliDNSClient(cred, regionID)
 	if err != nil {
 		return nil, err
 	}
+	return newDNSProviderWithClient(client), nil
+}
+
+// newAliDNSClient 使用指定凭据创建 SDK 客户端，regionID 为空时使用环境变量 ALIBABA_CLOUD_REGION_ID，每次请求记录指标
+func newAliDNSClient(cred credential.Credential, regionID string) (AliDNSClient, error) {
 	endpoint := getEndpoint()
+	if regionID != "" {
+		endpoint = endpointForRegion(regionID)
+	}
 
 	config := &openapi.Config{
-		Credential: credential,
+		Credential: cred,
 		Endpoint:   tea.String(endpoint),
 	}
-	alidnsClient, err := alidns.NewClient(config)
+	client, err := alidns.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
+	return &instrumentedClient{client: client}, nil
+}
+
+// newDNSProviderWithClient 使用指定的 AliDNSClient 创建 dnsProvider
+func newDNSProviderWithClient(client AliDNSClient) *dnsProvider {
 	return &dnsProvider{
-		client: alidnsClient,
-	}, nil
+		client:  client,
+		zones:   &zoneResolver{client: client},
+		minTTLs: &minTTLCache{},
+	}
+}
+
+// ResolveDomain 查找账号中覆盖 fqdn 的最长域名
+func (p *dnsProvider) ResolveDomain(ctx context.Context, fqdn string) (string, string, error) {
+	return p.zones.ResolveDomain(ctx, fqdn)
 }
 
 // AddTXTRecord 添加 TXT 记录
-func (p *dnsProvider) AddTXTRecord(domain, rr, value string) (string, error) {
+func (p *dnsProvider) AddTXTRecord(ctx context.Context, domain, rr, value string, opts RecordOptions) (string, error) {
 	// 查询现有记录
-	records, err := p.DescribeRecords(domain, rr)
+	records, err := p.FindRecords(ctx, domain, rr)
 	if err != nil {
 		return "", fmt.Errorf("failed to describe records: %w", err)
 	}
 
-	// 检查是否已存在相同值的记录
-	for _, record := range records {
-		if record.Value != nil && *record.Value == value {
-			// 记录已存在，直接返回
-			return *record.RecordId, nil
-		}
+	// 检查同一线路上是否已存在相同值的记录
+	if record := findRecordByValue(records, value, opts.Line); record != nil {
+		return p.reuseRecord(ctx, domain, rr, record)
 	}
 
 	// 添加新记录
@@ -82,9 +150,27 @@ func (p *dnsProvider) AddTXTRecord(domain, rr, value string) (string, error) {
 		Type:       tea.String("TXT"),
 		Value:      tea.String(value),
 	}
+	if opts.Line != "" {
+		request.Line = tea.String(opts.Line)
+	}
+	if ttl, ok := p.recordTTL(ctx, domain, opts.TTL); ok {
+		request.TTL = tea.Int64(ttl)
+	}
 
-	runtime := &util.RuntimeOptions{}
-	response, err := p.client.AddDomainRecordWithOptions(request, runtime)
+	response, err := callWithRetry(ctx, "AddDomainRecord", func(runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+		return p.client.AddDomainRecordWithOptions(request, runtime)
+	})
+	if isErrorCode(err, "DomainRecordDuplicate") {
+		// 其他请求（例如另一个副本）已经添加了相同的记录，查询其 ID 后视为成功
+		records, err := p.FindRecords(ctx, domain, rr)
+		if err != nil {
+			return "", fmt.Errorf("failed to describe records: %w", err)
+		}
+		if record := findRecordByValue(records, value, opts.Line); record != nil {
+			return p.reuseRecord(ctx, domain, rr, record)
+		}
+		return "", fmt.Errorf("failed to add domain record: record reported as duplicate but not found")
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to add domain record: %w", err)
 	}
@@ -93,14 +179,73 @@ func (p *dnsProvider) AddTXTRecord(domain, rr, value string) (string, error) {
 	return recordId, nil
 }
 
+// reuseRecord 返回已存在记录的 ID。被暂停的记录不会被解析，先重新启用；
+// 被锁定的记录无法启用，之后也无法删除，返回错误
+func (p *dnsProvider) reuseRecord(ctx context.Context, domain, rr string, record *alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord) (string, error) {
+	recordId := *record.RecordId
+	if tea.BoolValue(record.Locked) {
+		return "", fmt.Errorf("TXT record %s for %s in %s cannot be modified, unlock it in the AliDNS console: %w", recordId, rr, domain, errRecordLocked)
+	}
+	if strings.EqualFold(tea.StringValue(record.Status), recordStatusDisable) {
+		slog.Info("Re-enabling disabled TXT record",
+			"domain", domain,
+			"rr", rr,
+			"recordId", recordId,
+		)
+		if err := p.enableRecord(ctx, recordId); err != nil {
+			return "", err
+		}
+	}
+	return recordId, nil
+}
+
+// enableRecord 启用被暂停的记录
+func (p *dnsProvider) enableRecord(ctx context.Context, recordId string) error {
+	request := &alidns.SetDomainRecordStatusRequest{
+		RecordId: tea.String(recordId),
+		Status:   tea.String(recordStatusEnable),
+	}
+
+	_, err := callWithRetry(ctx, "SetDomainRecordStatus", func(runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error) {
+		return p.client.SetDomainRecordStatusWithOptions(request, runtime)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable domain record: %w", err)
+	}
+
+	return nil
+}
+
+// findRecordByValue 返回 records 中线路为 line 且记录值等于 value 的记录
+func findRecordByValue(records []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord, value, line string) *alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord {
+	for _, record := range records {
+		if record.Value != nil && *record.Value == value && record.RecordId != nil && sameLine(record.Line, line) {
+			return record
+		}
+	}
+	return nil
+}
+
+// sameLine 判断记录的线路是否为 line，空线路等同于默认线路 default
+func sameLine(recordLine *string, line string) bool {
+	if line == "" {
+		line = defaultLine
+	}
+	if recordLine == nil || *recordLine == "" {
+		return line == defaultLine
+	}
+	return *recordLine == line
+}
+
 // DeleteRecord 删除 TXT 记录
-func (p *dnsProvider) DeleteRecord(recordId string) error {
+func (p *dnsProvider) DeleteRecord(ctx context.Context, recordId string) error {
 	request := &alidns.DeleteDomainRecordRequest{
 		RecordId: tea.String(recordId),
 	}
 
-	runtime := &util.RuntimeOptions{}
-	_, err := p.client.DeleteDomainRecordWithOptions(request, runtime)
+	_, err := callWithRetry(ctx, "DeleteDomainRecord", func(runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+		return p.client.DeleteDomainRecordWithOptions(request, runtime)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete domain record: %w", err)
 	}
@@ -108,10 +253,10 @@ func (p *dnsProvider) DeleteRecord(recordId string) error {
 	return nil
 }
 
-// DeleteRecordsByKey 根据 domain、rr、value 删除记录
-func (p *dnsProvider) DeleteRecordsByKey(domain, rr, value string) error {
+// DeleteRecordsByKey 根据 domain、rr、value 删除记录，跳过不属于 owner 的记录
+func (p *dnsProvider) DeleteRecordsByKey(ctx context.Context, domain, rr, value, owner string) error {
 	// 查询记录
-	records, err := p.DescribeRecords(domain, rr)
+	records, err := p.FindRecords(ctx, domain, rr)
 	if err != nil {
 		return fmt.Errorf("failed to describe records: %w", err)
 	}
@@ -119,7 +264,17 @@ func (p *dnsProvider) DeleteRecordsByKey(domain, rr, value string) error {
 	// 删除匹配的记录
 	for _, record := range records {
 		if record.Value != nil && *record.Value == value {
-			if err := p.DeleteRecord(*record.RecordId); err != nil {
+			if !ownedBy(record.Remark, owner) {
+				slog.Warn("Refusing to delete TXT record not owned by this webhook",
+					"domain", domain,
+					"rr", rr,
+					"recordId", tea.StringValue(record.RecordId),
+					"remark", tea.StringValue(record.Remark),
+					"owner", owner,
+				)
+				continue
+			}
+			if err := p.DeleteRecord(ctx, *record.RecordId); err != nil {
 				return err
 			}
 		}
@@ -128,8 +283,120 @@ func (p *dnsProvider) DeleteRecordsByKey(domain, rr, value string) error {
 	return nil
 }
 
-// DescribeRecords 查询记录
-func (p *dnsProvider) DescribeRecords(domain, rr string) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
+// SetRecordRemark 设置记录的备注
+func (p *dnsProvider) SetRecordRemark(ctx context.Context, recordId, remark string) error {
+	request := &alidns.UpdateDomainRecordRemarkRequest{
+		RecordId: tea.String(recordId),
+		Remark:   tea.String(remark),
+	}
+
+	_, err := callWithRetry(ctx, "UpdateDomainRecordRemark", func(runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error) {
+		return p.client.UpdateDomainRecordRemarkWithOptions(request, runtime)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update domain record remark: %w", err)
+	}
+
+	return nil
+}
+
+// Nameservers 返回云解析为 domain 分配的权威 DNS 服务器
+func (p *dnsProvider) Nameservers(ctx context.Context, domain string) ([]string, error) {
+	info, err := p.describeDomainInfo(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var nameservers []string
+	if info.DnsServers != nil {
+		for _, server := range info.DnsServers.DnsServer {
+			if server != nil && *server != "" {
+				nameservers = append(nameservers, *server)
+			}
+		}
+	}
+	return nameservers, nil
+}
+
+// describeDomainInfo 查询 domain 的详细信息，包括权威 DNS 服务器和版本允许的最小 TTL
+func (p *dnsProvider) describeDomainInfo(ctx context.Context, domain string) (*alidns.DescribeDomainInfoResponseBody, error) {
+	request := &alidns.DescribeDomainInfoRequest{
+		DomainName: tea.String(domain),
+	}
+
+	response, err := callWithRetry(ctx, "DescribeDomainInfo", func(runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+		return p.client.DescribeDomainInfoWithOptions(request, runtime)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe domain info: %w", err)
+	}
+	return response.Body, nil
+}
+
+// FindRecords 精确查询主机记录等于 rr 的 TXT 记录。
+// DescribeSubDomainRecords 按完整子域名匹配，不会像 RRKeyWord 一样模糊匹配到
+// _acme-challenge.www 等记录，返回前仍会再校验一次 RR。
+func (p *dnsProvider) FindRecords(ctx context.Context, domain, rr string) ([]*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord, error) {
+	subDomain := domain
+	if rr != "" && rr != "@" {
+		subDomain = rr + "." + domain
+	}
+
+	var matched []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord
+	fetched := 0
+	pageNumber := int64(1)
+	pageSize := int64(pageSizeRequest)
+
+	for {
+		request := &alidns.DescribeSubDomainRecordsRequest{
+			DomainName: tea.String(domain),
+			SubDomain:  tea.String(subDomain),
+			Type:       tea.String(recordType),
+			PageNumber: tea.Int64(pageNumber),
+			PageSize:   tea.Int64(pageSize),
+		}
+
+		response, err := callWithRetry(ctx, "DescribeSubDomainRecords", func(runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+			return p.client.DescribeSubDomainRecordsWithOptions(request, runtime)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe sub domain records: %w", err)
+		}
+
+		var page []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord
+		if response.Body.DomainRecords != nil {
+			page = response.Body.DomainRecords.Record
+		}
+		fetched += len(page)
+		for _, record := range page {
+			if sameRR(record.RR, rr) {
+				matched = append(matched, record)
+			}
+		}
+
+		// 如果没有更多记录，退出循环
+		if len(page) == 0 || response.Body.TotalCount == nil || int64(fetched) >= *response.Body.TotalCount {
+			break
+		}
+		pageNumber++
+	}
+
+	return matched, nil
+}
+
+// sameRR 判断记录的主机记录是否与 rr 完全一致（不区分大小写）
+func sameRR(recordRR *string, rr string) bool {
+	if recordRR == nil {
+		return false
+	}
+	if rr == "" {
+		rr = "@"
+	}
+	return strings.EqualFold(*recordRR, rr)
+}
+
+// DescribeRecords 模糊查询主机记录包含 rr 的记录，用于按 zone 扫描
+func (p *dnsProvider) DescribeRecords(ctx context.Context, domain, rr string) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
 	var allRecords []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
 	pageNumber := int64(1)
 	pageSize := int64(pageSizeRequest)
@@ -143,18 +410,21 @@ func (p *dnsProvider) DescribeRecords(domain, rr string) ([]*alidns.DescribeDoma
 			PageSize:   tea.Int64(pageSize),
 		}
 
-		runtime := &util.RuntimeOptions{}
-		response, err := p.client.DescribeDomainRecordsWithOptions(request, runtime)
+		response, err := callWithRetry(ctx, "DescribeDomainRecords", func(runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error) {
+			return p.client.DescribeDomainRecordsWithOptions(request, runtime)
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to describe domain records: %w", err)
 		}
 
-		if response.Body.DomainRecords != nil && response.Body.DomainRecords.Record != nil {
-			allRecords = append(allRecords, response.Body.DomainRecords.Record...)
+		var page []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+		if response.Body.DomainRecords != nil {
+			page = response.Body.DomainRecords.Record
 		}
+		allRecords = append(allRecords, page...)
 
 		// 如果没有更多记录，退出循环
-		if response.Body.TotalCount == nil || int64(len(allRecords)) >= *response.Body.TotalCount {
+		if len(page) == 0 || response.Body.TotalCount == nil || int64(len(allRecords)) >= *response.Body.TotalCount {
 			break
 		}
 		pageNumber++
@@ -164,7 +434,10 @@ func (p *dnsProvider) DescribeRecords(domain, rr string) ([]*alidns.DescribeDoma
 }
 
 func getEndpoint() string {
-	region := os.Getenv("ALIBABA_CLOUD_REGION_ID")
+	return endpointForRegion(os.Getenv("ALIBABA_CLOUD_REGION_ID"))
+}
+
+func endpointForRegion(region string) string {
 	if region == "" {
 		return defaultEndpoint
 	}
@@ -1,6 +1,7 @@
 package alidns
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -16,9 +17,14 @@ import (
 // MockAliDNSClient 是用于测试的 mock 客户端
 type MockAliDNSClient struct {
 	// 可配置的 mock 行为
-	AddDomainRecordFunc       func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error)
-	DeleteDomainRecordFunc    func(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error)
-	DescribeDomainRecordsFunc func(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error)
+	AddDomainRecordFunc          func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error)
+	DeleteDomainRecordFunc       func(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error)
+	DescribeDomainRecordsFunc    func(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error)
+	DescribeDomainsFunc          func(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error)
+	DescribeDomainInfoFunc       func(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error)
+	DescribeSubDomainRecordsFunc func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error)
+	SetDomainRecordStatusFunc    func(request *alidns.SetDomainRecordStatusRequest, runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error)
+	UpdateDomainRecordRemarkFunc func(request *alidns.UpdateDomainRecordRemarkRequest, runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error)
 }
 
 func (m *MockAliDNSClient) AddDomainRecordWithOptions(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
@@ -53,25 +59,77 @@ func (m *MockAliDNSClient) DescribeDomainRecordsWithOptions(request *alidns.Desc
 	}, nil
 }
 
+func (m *MockAliDNSClient) DescribeDomainsWithOptions(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+	if m.DescribeDomainsFunc != nil {
+		return m.DescribeDomainsFunc(request, runtime)
+	}
+	return &alidns.DescribeDomainsResponse{
+		Body: &alidns.DescribeDomainsResponseBody{
+			TotalCount: tea.Int64(0),
+			Domains: &alidns.DescribeDomainsResponseBodyDomains{
+				Domain: []*alidns.DescribeDomainsResponseBodyDomainsDomain{},
+			},
+		},
+	}, nil
+}
+
+func (m *MockAliDNSClient) DescribeDomainInfoWithOptions(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+	if m.DescribeDomainInfoFunc != nil {
+		return m.DescribeDomainInfoFunc(request, runtime)
+	}
+	return &alidns.DescribeDomainInfoResponse{
+		Body: &alidns.DescribeDomainInfoResponseBody{},
+	}, nil
+}
+
+func (m *MockAliDNSClient) DescribeSubDomainRecordsWithOptions(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+	if m.DescribeSubDomainRecordsFunc != nil {
+		return m.DescribeSubDomainRecordsFunc(request, runtime)
+	}
+	return &alidns.DescribeSubDomainRecordsResponse{
+		Body: &alidns.DescribeSubDomainRecordsResponseBody{
+			TotalCount: tea.Int64(0),
+			DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{
+				Record: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{},
+			},
+		},
+	}, nil
+}
+
+func (m *MockAliDNSClient) SetDomainRecordStatusWithOptions(request *alidns.SetDomainRecordStatusRequest, runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error) {
+	if m.SetDomainRecordStatusFunc != nil {
+		return m.SetDomainRecordStatusFunc(request, runtime)
+	}
+	return &alidns.SetDomainRecordStatusResponse{}, nil
+}
+
+func (m *MockAliDNSClient) UpdateDomainRecordRemarkWithOptions(request *alidns.UpdateDomainRecordRemarkRequest, runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error) {
+	if m.UpdateDomainRecordRemarkFunc != nil {
+		return m.UpdateDomainRecordRemarkFunc(request, runtime)
+	}
+	return &alidns.UpdateDomainRecordRemarkResponse{}, nil
+}
+
 func TestAddTXTRecord(t *testing.T) {
 	tests := []struct {
 		name            string
-		existingRecords []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+		existingRecords []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord
 		addFuncCalled   bool
 		expectError     bool
 		errorMsg        string
 	}{
 		{
 			name:            "new record - should create",
-			existingRecords: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{},
+			existingRecords: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{},
 			addFuncCalled:   true,
 			expectError:     false,
 		},
 		{
 			name: "record already exists - should return existing",
-			existingRecords: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			existingRecords: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
 				{
 					RecordId: tea.String("existing-id"),
+					RR:       tea.String("_acme-challenge"),
 					Value:    tea.String("test-value"),
 				},
 			},
@@ -87,7 +145,7 @@ func TestAddTXTRecord(t *testing.T) {
 		},
 		{
 			name:            "add API error",
-			existingRecords: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{},
+			existingRecords: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{},
 			addFuncCalled:   true,
 			expectError:     true,
 			errorMsg:        "failed to add domain record",
@@ -98,14 +156,14 @@ func TestAddTXTRecord(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			addCalled := false
 			mockClient := &MockAliDNSClient{
-				DescribeDomainRecordsFunc: func(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error) {
+				DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
 					if tt.existingRecords == nil && tt.expectError && tt.name == "describe API error" {
 						return nil, errors.New("describe API error")
 					}
-					return &alidns.DescribeDomainRecordsResponse{
-						Body: &alidns.DescribeDomainRecordsResponseBody{
+					return &alidns.DescribeSubDomainRecordsResponse{
+						Body: &alidns.DescribeSubDomainRecordsResponseBody{
 							TotalCount: tea.Int64(int64(len(tt.existingRecords))),
-							DomainRecords: &alidns.DescribeDomainRecordsResponseBodyDomainRecords{
+							DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{
 								Record: tt.existingRecords,
 							},
 						},
@@ -125,7 +183,7 @@ func TestAddTXTRecord(t *testing.T) {
 			}
 
 			provider := &dnsProvider{client: mockClient}
-			recordID, err := provider.AddTXTRecord("example.com", "_acme-challenge", "test-value")
+			recordID, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{})
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -146,6 +204,174 @@ func TestAddTXTRecord(t *testing.T) {
 	}
 }
 
+func TestAddTXTRecord_Duplicate(t *testing.T) {
+	// 记录在查询和添加之间被其他请求创建时，返回已存在记录的 ID
+	describeCalls := 0
+	mockClient := &MockAliDNSClient{
+		DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+			describeCalls++
+			records := []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{}
+			if describeCalls > 1 {
+				records = append(records, &alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
+					RecordId: tea.String("existing-id"),
+					RR:       tea.String("_acme-challenge"),
+					Value:    tea.String("test-value"),
+				})
+			}
+			return &alidns.DescribeSubDomainRecordsResponse{
+				Body: &alidns.DescribeSubDomainRecordsResponseBody{
+					TotalCount:    tea.Int64(int64(len(records))),
+					DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{Record: records},
+				},
+			}, nil
+		},
+		AddDomainRecordFunc: func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+			return nil, newSDKError("DomainRecordDuplicate", 400)
+		},
+	}
+
+	provider := newDNSProviderWithClient(mockClient)
+	recordID, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "existing-id", recordID)
+	assert.Equal(t, 2, describeCalls)
+
+	// 报告重复却查不到记录时返回错误
+	describeCalls = -10
+	_, err = provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{})
+	assert.ErrorContains(t, err, "duplicate but not found")
+}
+
+func TestAddTXTRecord_Line(t *testing.T) {
+	tests := []struct {
+		name         string
+		existingLine *string
+		line         string
+		expectAdd    bool
+	}{
+		{name: "default line matches record without line", existingLine: nil, line: "", expectAdd: false},
+		{name: "default line matches explicit default", existingLine: tea.String("default"), line: "", expectAdd: false},
+		{name: "explicit default matches record without line", existingLine: nil, line: "default", expectAdd: false},
+		{name: "same line", existingLine: tea.String("telecom"), line: "telecom", expectAdd: false},
+		{name: "other line", existingLine: tea.String("default"), line: "telecom", expectAdd: true},
+		{name: "default line ignores other line", existingLine: tea.String("telecom"), line: "", expectAdd: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added *alidns.AddDomainRecordRequest
+			mockClient := &MockAliDNSClient{
+				DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+					records := []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{{
+						RecordId: tea.String("existing-id"),
+						RR:       tea.String("_acme-challenge"),
+						Value:    tea.String("test-value"),
+						Line:     tt.existingLine,
+					}}
+					return &alidns.DescribeSubDomainRecordsResponse{
+						Body: &alidns.DescribeSubDomainRecordsResponseBody{
+							TotalCount:    tea.Int64(int64(len(records))),
+							DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{Record: records},
+						},
+					}, nil
+				},
+				AddDomainRecordFunc: func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+					added = request
+					return &alidns.AddDomainRecordResponse{
+						Body: &alidns.AddDomainRecordResponseBody{RecordId: tea.String("new-record-id")},
+					}, nil
+				},
+			}
+
+			provider := &dnsProvider{client: mockClient}
+			recordID, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{Line: tt.line})
+			require.NoError(t, err)
+			if !tt.expectAdd {
+				assert.Equal(t, "existing-id", recordID)
+				assert.Nil(t, added)
+				return
+			}
+			assert.Equal(t, "new-record-id", recordID)
+			require.NotNil(t, added)
+			if tt.line == "" {
+				assert.Nil(t, added.Line)
+			} else {
+				assert.Equal(t, tt.line, tea.StringValue(added.Line))
+			}
+		})
+	}
+}
+
+func TestAddTXTRecord_Status(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       *string
+		locked       *bool
+		enableErr    error
+		expectEnable bool
+		expectError  error
+	}{
+		{name: "enabled record", status: tea.String("ENABLE"), expectEnable: false},
+		{name: "record without status", status: nil, expectEnable: false},
+		{name: "disabled record is re-enabled", status: tea.String("DISABLE"), expectEnable: true},
+		{name: "enable failure", status: tea.String("DISABLE"), enableErr: newSDKError("Forbidden.RAM", 403), expectEnable: true, expectError: errors.New("failed to enable domain record")},
+		{name: "locked record", status: tea.String("ENABLE"), locked: tea.Bool(true), expectError: errRecordLocked},
+		{name: "locked disabled record", status: tea.String("DISABLE"), locked: tea.Bool(true), expectError: errRecordLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enabled *alidns.SetDomainRecordStatusRequest
+			mockClient := &MockAliDNSClient{
+				DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+					records := []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{{
+						RecordId: tea.String("existing-id"),
+						RR:       tea.String("_acme-challenge"),
+						Value:    tea.String("test-value"),
+						Status:   tt.status,
+						Locked:   tt.locked,
+					}}
+					return &alidns.DescribeSubDomainRecordsResponse{
+						Body: &alidns.DescribeSubDomainRecordsResponseBody{
+							TotalCount:    tea.Int64(int64(len(records))),
+							DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{Record: records},
+						},
+					}, nil
+				},
+				AddDomainRecordFunc: func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+					t.Fatal("existing record should be reused")
+					return nil, nil
+				},
+				SetDomainRecordStatusFunc: func(request *alidns.SetDomainRecordStatusRequest, runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error) {
+					enabled = request
+					return nil, tt.enableErr
+				},
+			}
+
+			provider := newDNSProviderWithClient(mockClient)
+			recordID, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{})
+
+			if tt.expectEnable {
+				require.NotNil(t, enabled)
+				assert.Equal(t, "existing-id", tea.StringValue(enabled.RecordId))
+				assert.Equal(t, "Enable", tea.StringValue(enabled.Status))
+			} else {
+				assert.Nil(t, enabled)
+			}
+			switch {
+			case errors.Is(tt.expectError, errRecordLocked):
+				assert.ErrorIs(t, err, errRecordLocked)
+				assert.ErrorContains(t, err, "existing-id")
+			case tt.expectError != nil:
+				assert.ErrorContains(t, err, tt.expectError.Error())
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "existing-id", recordID)
+			}
+		})
+	}
+}
+
 func TestDeleteRecord(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -178,7 +404,7 @@ func TestDeleteRecord(t *testing.T) {
 			}
 
 			provider := &dnsProvider{client: mockClient}
-			err := provider.DeleteRecord(tt.recordID)
+			err := provider.DeleteRecord(context.Background(), tt.recordID)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -193,15 +419,16 @@ func TestDeleteRecord(t *testing.T) {
 func TestDeleteRecordsByKey(t *testing.T) {
 	tests := []struct {
 		name         string
-		records      []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
+		records      []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord
 		expectDelete int
 		expectError  bool
 	}{
 		{
 			name: "single matching record",
-			records: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			records: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
 				{
 					RecordId: tea.String("record-1"),
+					RR:       tea.String("_acme-challenge"),
 					Value:    tea.String("target-value"),
 				},
 			},
@@ -210,13 +437,15 @@ func TestDeleteRecordsByKey(t *testing.T) {
 		},
 		{
 			name: "multiple matching records",
-			records: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			records: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
 				{
 					RecordId: tea.String("record-1"),
+					RR:       tea.String("_acme-challenge"),
 					Value:    tea.String("target-value"),
 				},
 				{
 					RecordId: tea.String("record-2"),
+					RR:       tea.String("_acme-challenge"),
 					Value:    tea.String("target-value"),
 				},
 			},
@@ -225,7 +454,7 @@ func TestDeleteRecordsByKey(t *testing.T) {
 		},
 		{
 			name:         "no matching records",
-			records:      []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{},
+			records:      []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{},
 			expectDelete: 0,
 			expectError:  false,
 		},
@@ -237,9 +466,10 @@ func TestDeleteRecordsByKey(t *testing.T) {
 		},
 		{
 			name: "delete API error",
-			records: []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			records: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
 				{
 					RecordId: tea.String("record-1"),
+					RR:       tea.String("_acme-challenge"),
 					Value:    tea.String("target-value"),
 				},
 			},
@@ -252,14 +482,14 @@ func TestDeleteRecordsByKey(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			deleteCalled := 0
 			mockClient := &MockAliDNSClient{
-				DescribeDomainRecordsFunc: func(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error) {
+				DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
 					if tt.name == "describe API error" {
 						return nil, errors.New("describe API error")
 					}
-					return &alidns.DescribeDomainRecordsResponse{
-						Body: &alidns.DescribeDomainRecordsResponseBody{
+					return &alidns.DescribeSubDomainRecordsResponse{
+						Body: &alidns.DescribeSubDomainRecordsResponseBody{
 							TotalCount: tea.Int64(int64(len(tt.records))),
-							DomainRecords: &alidns.DescribeDomainRecordsResponseBodyDomainRecords{
+							DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{
 								Record: tt.records,
 							},
 						},
@@ -275,7 +505,7 @@ func TestDeleteRecordsByKey(t *testing.T) {
 			}
 
 			provider := &dnsProvider{client: mockClient}
-			err := provider.DeleteRecordsByKey("example.com", "_acme-challenge", "target-value")
+			err := provider.DeleteRecordsByKey(context.Background(), "example.com", "_acme-challenge", "target-value", "")
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -291,9 +521,10 @@ func TestDescribeRecords(t *testing.T) {
 	tests := []struct {
 		name           string
 		totalCount     int64
-		recordsPerPage int // Mock 每次返回的记录数
-		expectCalls    int // 期望调用 API 的次数
-		expectCount    int // 期望返回的总记录数
+		recordsPerPage int   // Mock 每次返回的记录数
+		available      int64 // Mock 实际能返回的记录数，为 0 时等于 totalCount
+		expectCalls    int   // 期望调用 API 的次数
+		expectCount    int   // 期望返回的总记录数
 		expectError    bool
 	}{
 		{
@@ -328,6 +559,15 @@ func TestDescribeRecords(t *testing.T) {
 			expectCount:    250,
 			expectError:    false,
 		},
+		{
+			name:           "total count larger than available records",
+			totalCount:     150,
+			recordsPerPage: 100,
+			available:      100,
+			expectCalls:    2,
+			expectCount:    100,
+			expectError:    false,
+		},
 		{
 			name:           "empty result",
 			totalCount:     0,
@@ -364,7 +604,11 @@ func TestDescribeRecords(t *testing.T) {
 					assert.Equal(t, int64(callCount), *request.PageNumber)
 
 					// 计算这次调用应该返回多少条记录
-					remaining := tt.totalCount - int64((callCount-1)*tt.recordsPerPage)
+					available := tt.totalCount
+					if tt.available > 0 {
+						available = tt.available
+					}
+					remaining := available - int64((callCount-1)*tt.recordsPerPage)
 					records := []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{}
 
 					if remaining > 0 && tt.name != "empty result" {
@@ -391,7 +635,7 @@ func TestDescribeRecords(t *testing.T) {
 			}
 
 			provider := &dnsProvider{client: mockClient}
-			records, err := provider.DescribeRecords("example.com", "_acme-challenge")
+			records, err := provider.DescribeRecords(context.Background(), "example.com", "_acme-challenge")
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -405,6 +649,117 @@ func TestDescribeRecords(t *testing.T) {
 	}
 }
 
+func TestFindRecords(t *testing.T) {
+	tests := []struct {
+		name            string
+		rr              string
+		totalCount      int
+		expectSubDomain string
+		expectPages     int
+		expectCount     int
+	}{
+		{
+			name:            "single page",
+			rr:              "_acme-challenge",
+			totalCount:      3,
+			expectSubDomain: "_acme-challenge.example.com",
+			expectPages:     1,
+			expectCount:     3,
+		},
+		{
+			name:            "multiple pages",
+			rr:              "_acme-challenge",
+			totalCount:      250,
+			expectSubDomain: "_acme-challenge.example.com",
+			expectPages:     3,
+			expectCount:     250,
+		},
+		{
+			name:            "apex record",
+			rr:              "@",
+			totalCount:      1,
+			expectSubDomain: "example.com",
+			expectPages:     1,
+			expectCount:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := 0
+			mockClient := &MockAliDNSClient{
+				DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+					pages++
+					assert.Equal(t, "example.com", *request.DomainName)
+					assert.Equal(t, tt.expectSubDomain, *request.SubDomain)
+					assert.Equal(t, "TXT", *request.Type)
+					assert.Equal(t, int64(pages), *request.PageNumber)
+
+					start := (int(*request.PageNumber) - 1) * int(*request.PageSize)
+					end := min(start+int(*request.PageSize), tt.totalCount)
+					records := []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{}
+					for i := start; i < end; i++ {
+						records = append(records, &alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
+							RecordId: tea.String(fmt.Sprintf("record-%d", i)),
+							RR:       tea.String(tt.rr),
+							Value:    tea.String(fmt.Sprintf("value-%d", i)),
+						})
+					}
+					return &alidns.DescribeSubDomainRecordsResponse{
+						Body: &alidns.DescribeSubDomainRecordsResponseBody{
+							TotalCount: tea.Int64(int64(tt.totalCount)),
+							DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{
+								Record: records,
+							},
+						},
+					}, nil
+				},
+			}
+
+			provider := &dnsProvider{client: mockClient}
+			records, err := provider.FindRecords(context.Background(), "example.com", tt.rr)
+
+			require.NoError(t, err)
+			assert.Len(t, records, tt.expectCount)
+			assert.Equal(t, tt.expectPages, pages)
+		})
+	}
+}
+
+func TestFindRecords_IgnoresOtherRR(t *testing.T) {
+	// 即使接口返回了主机记录不同的记录，也不能被当作同一条记录处理
+	mockClient := &MockAliDNSClient{
+		DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+			return &alidns.DescribeSubDomainRecordsResponse{
+				Body: &alidns.DescribeSubDomainRecordsResponseBody{
+					TotalCount: tea.Int64(3),
+					DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{
+						Record: []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
+							{RecordId: tea.String("www"), RR: tea.String("_acme-challenge.www"), Value: tea.String("target-value")},
+							{RecordId: tea.String("exact"), RR: tea.String("_ACME-Challenge"), Value: tea.String("target-value")},
+							{RecordId: tea.String("prefix"), RR: tea.String("x_acme-challenge"), Value: tea.String("target-value")},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	provider := &dnsProvider{client: mockClient}
+	records, err := provider.FindRecords(context.Background(), "example.com", "_acme-challenge")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "exact", *records[0].RecordId)
+
+	var deleted []string
+	mockClient.DeleteDomainRecordFunc = func(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+		deleted = append(deleted, *request.RecordId)
+		return &alidns.DeleteDomainRecordResponse{}, nil
+	}
+	require.NoError(t, provider.DeleteRecordsByKey(context.Background(), "example.com", "_acme-challenge", "target-value", ""))
+	assert.Equal(t, []string{"exact"}, deleted)
+}
+
 func TestGetEndpoint(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -0,0 +1,79 @@
+package alidns
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// configMapStore 把键值对保存在 webhook 所在 namespace 的一个 ConfigMap 中，
+// ConfigMap 不存在时在第一次写入时创建。多个副本并发写入时按冲突重试
+type configMapStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// get 返回 key 对应的值
+func (c *configMapStore) get(ctx context.Context, key string) (string, bool, error) {
+	data, err := c.list(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := data[key]
+	return value, ok, nil
+}
+
+// list 返回 ConfigMap 中的全部数据，ConfigMap 不存在时返回空
+func (c *configMapStore) list(ctx context.Context) (map[string]string, error) {
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cm.Data == nil {
+		return map[string]string{}, nil
+	}
+	return cm.Data, nil
+}
+
+// update 读取 ConfigMap 的数据交给 mutate 修改后写回，mutate 返回 false 时不写入
+func (c *configMapStore) update(ctx context.Context, mutate func(data map[string]string) bool) error {
+	configMaps := c.client.CoreV1().ConfigMaps(c.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, c.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			data := map[string]string{}
+			if !mutate(data) {
+				return nil
+			}
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: c.name, Namespace: c.namespace},
+				Data:       data,
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// 与其他副本同时创建，重新读取后更新
+				return apierrors.NewConflict(corev1.Resource("configmaps"), c.name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		if !mutate(cm.Data) {
+			return nil
+		}
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,400 @@
+package alidns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	credential "github.com/aliyun/credentials-go/credentials"
+	"github.com/aliyun/credentials-go/credentials/providers"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultSessionDuration 是扮演 RAM 角色时默认的 STS Token 有效期
+	defaultSessionDuration = time.Hour
+	// sessionExpiryMargin 提前淘汰缓存，与 credentials-go 提前刷新 STS Token 的时间保持一致
+	sessionExpiryMargin = 3 * time.Minute
+	// defaultRoleSessionName 是未配置 roleSessionName 时使用的会话名称
+	defaultRoleSessionName = "cert-manager-alidns-webhook"
+)
+
+// hasSecretCredentials 判断 Issuer 是否配置了 AccessKey Secret 引用
+func (c *Config) hasSecretCredentials() bool {
+	return c.AccessKeyIDSecretRef != nil || c.AccessKeySecretSecretRef != nil || c.SecurityTokenSecretRef != nil
+}
+
+// hasCredentials 判断 Issuer 是否配置了任何凭据相关的字段
+func (c *Config) hasCredentials() bool {
+	return c.hasSecretCredentials() || c.RoleArn != "" || c.ServiceAccountName != ""
+}
+
+// usesAmbientCredentials 判断配置是否依赖 webhook 自身的凭据，
+// 包括直接使用默认凭据链以及在默认凭据链之上扮演 RAM 角色
+func (c *Config) usesAmbientCredentials() bool {
+	return c.ServiceAccountName == "" && !c.hasSecretCredentials()
+}
+
+// usesPrivateZone 判断配置是否需要写入云解析 PrivateZone
+func (c *Config) usesPrivateZone() bool {
+	return c.Backend == backendPrivateZone || c.Backend == backendMirror
+}
+
+// validateBackend 检查 backend 和 mirrorPolicy 是否为支持的取值
+func (c *Config) validateBackend() error {
+	switch c.Backend {
+	case "", backendAliDNS, backendPrivateZone, backendMirror:
+	default:
+		return fmt.Errorf("invalid backend %q: must be %s, %s or %s", c.Backend, backendAliDNS, backendPrivateZone, backendMirror)
+	}
+	switch c.MirrorPolicy {
+	case "", mirrorPolicyAll, mirrorPolicyPublic:
+	default:
+		return fmt.Errorf("invalid mirrorPolicy %q: must be %s or %s", c.MirrorPolicy, mirrorPolicyAll, mirrorPolicyPublic)
+	}
+	return nil
+}
+
+// clientKey 返回缓存 DNSProvider 时区分 backend 和 endpoint 的部分
+func (c *Config) clientKey() string {
+	switch c.Backend {
+	case backendPrivateZone:
+		return backendPrivateZone + "|" + c.VpcID
+	case backendMirror:
+		return backendMirror + "|" + c.RegionID + "|" + c.VpcID + "|" + c.MirrorPolicy
+	}
+	return c.RegionID
+}
+
+// sessionDuration 返回扮演角色时使用的 STS Token 有效期
+func (c *Config) sessionDuration() time.Duration {
+	if c.SessionDuration <= 0 {
+		return defaultSessionDuration
+	}
+	return time.Duration(c.SessionDuration) * time.Second
+}
+
+// resolveProvider 解析 challenge 的配置并返回处理 domain 的 DNSProvider。
+// cert-manager 不允许使用 ambient credentials 时（例如未开启
+// --issuer-ambient-credentials 的 Issuer），必须在配置中显式指定凭据。
+// Issuer 未配置凭据时，优先使用 webhook 路由表中匹配 domain 的凭据。
+func (s *Solver) resolveProvider(ctx context.Context, ch *v1alpha1.ChallengeRequest, domain string) (DNSProvider, error) {
+	cfg, err := loadConfig(ch.Config)
+	if err != nil {
+		return nil, invalidConfigf("failed to load config: %w", err)
+	}
+
+	if !ch.AllowAmbientCredentials && cfg.usesAmbientCredentials() {
+		return nil, invalidConfigf("ambient credentials are not allowed for resources in namespace %q: "+
+			"set accessKeyIdSecretRef and accessKeySecretSecretRef (optionally with roleArn), "+
+			"or serviceAccountName and roleArn, in the webhook solver config; "+
+			"alternatively start cert-manager with --issuer-ambient-credentials to let Issuers use the webhook's own identity",
+			ch.ResourceNamespace)
+	}
+
+	// 路由表中的凭据属于 webhook 自身，同样视为 ambient credentials
+	if route := s.routeFor(cfg, domain); route != nil {
+		slog.Debug("Using zone route", "domain", domain, "zone", route.Zone)
+		return s.providerFor(ctx, &route.Config, s.namespace)
+	}
+
+	return s.providerFor(ctx, cfg, ch.ResourceNamespace)
+}
+
+// routeFor 返回为 domain 提供凭据的路由，Issuer 配置了自己的凭据时不使用路由表
+func (s *Solver) routeFor(cfg *Config, domain string) *ZoneRoute {
+	if cfg.hasCredentials() {
+		return nil
+	}
+	return s.routes.match(domain)
+}
+
+// providerFor 返回处理本次 challenge 的 DNSProvider。
+// Issuer 未配置凭据时使用 webhook 自身的 dnsProvider。
+func (s *Solver) providerFor(ctx context.Context, cfg *Config, namespace string) (DNSProvider, error) {
+	if cfg.ServiceAccountName != "" {
+		return s.rrsaProviderFor(cfg, namespace)
+	}
+
+	if !cfg.hasSecretCredentials() && cfg.RoleArn == "" {
+		if cfg.RegionID == "" && !cfg.usesPrivateZone() {
+			if s.dnsProvider == nil {
+				return nil, fmt.Errorf("alidns client not initialized")
+			}
+			return s.dnsProvider, nil
+		}
+		// 指定了 region 或使用 PrivateZone 时使用默认凭据链创建对应 endpoint 的客户端
+		return s.roleProviders.getOrCreate("default|"+cfg.clientKey(), defaultSessionDuration, func() (DNSProvider, error) {
+			return s.buildDNSProvider(providers.NewDefaultCredentialsProvider(), cfg, defaultAccount)
+		})
+	}
+
+	// 基础凭据：Secret 中的 AccessKey，未配置时使用默认凭据链。
+	// identity 是缓存 key 中标识基础凭据的部分，Secret 凭据包含 namespace 和凭据摘要，
+	// 只知道 AccessKey ID 的 Issuer 无法复用他人已缓存的 DNSProvider
+	var base providers.CredentialsProvider
+	baseID, identity := "default", "default"
+	if cfg.hasSecretCredentials() {
+		accessKeyID, cp, err := s.secretCredentialsProvider(ctx, cfg, namespace)
+		if err != nil {
+			return nil, err
+		}
+		fingerprint, err := credentialsFingerprint(cp)
+		if err != nil {
+			return nil, err
+		}
+		base, baseID = cp, accessKeyID
+		identity = fmt.Sprintf("secret|%s|%s|%s", namespace, accessKeyID, fingerprint)
+	} else {
+		base = providers.NewDefaultCredentialsProvider()
+	}
+
+	if cfg.RoleArn == "" {
+		// 缓存后 DescribeDomains 等缓存可以复用；Secret 中的 AccessKey 或 STS Token 更换后会创建新的 DNSProvider
+		return s.roleProviders.getOrCreate(identity+"|"+cfg.clientKey(), defaultSessionDuration, func() (DNSProvider, error) {
+			return s.buildDNSProvider(base, cfg, baseID)
+		})
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%s|%d|%s", identity, cfg.RoleArn, cfg.RoleSessionName, cfg.ExternalId, cfg.SessionDuration, cfg.clientKey())
+	return s.roleProviders.getOrCreate(key, cfg.sessionDuration()-sessionExpiryMargin, func() (DNSProvider, error) {
+		cp, err := ramRoleCredentialsProvider(base, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return s.buildDNSProvider(cp, cfg, roleAccount(cfg.RoleArn))
+	})
+}
+
+// rrsaProviderFor 使用 Issuer 所在 namespace 中 ServiceAccount 的 OIDC Token 扮演 RoleArn
+func (s *Solver) rrsaProviderFor(cfg *Config, namespace string) (DNSProvider, error) {
+	if cfg.RoleArn == "" {
+		return nil, invalidConfigf("roleArn must be set when serviceAccountName is used")
+	}
+	if cfg.hasSecretCredentials() {
+		return nil, invalidConfigf("serviceAccountName cannot be combined with secret credentials")
+	}
+	if s.kubeClient == nil {
+		return nil, fmt.Errorf("kubernetes client not initialized")
+	}
+
+	oidcProviderArn := cfg.OIDCProviderArn
+	if oidcProviderArn == "" {
+		oidcProviderArn = os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN")
+	}
+	if oidcProviderArn == "" {
+		return nil, invalidConfigf("oidcProviderArn must be set when ALIBABA_CLOUD_OIDC_PROVIDER_ARN is not configured on the webhook")
+	}
+
+	sessionName := cfg.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+	stsURL := s.stsURL
+	if stsURL == "" {
+		stsURL = getSTSURL()
+	}
+
+	key := fmt.Sprintf("rrsa|%s|%s|%s|%s|%s|%d|%s", namespace, cfg.ServiceAccountName, cfg.RoleArn, oidcProviderArn, sessionName, cfg.SessionDuration, cfg.clientKey())
+	return s.roleProviders.getOrCreate(key, cfg.sessionDuration()-sessionExpiryMargin, func() (DNSProvider, error) {
+		return s.buildDNSProvider(&oidcCredentialsProvider{
+			tokenSource:     serviceAccountTokenSource(s.kubeClient, namespace, cfg.ServiceAccountName),
+			roleArn:         cfg.RoleArn,
+			oidcProviderArn: oidcProviderArn,
+			roleSessionName: sessionName,
+			durationSeconds: int(cfg.sessionDuration() / time.Second),
+			stsURL:          stsURL,
+		}, cfg, roleAccount(cfg.RoleArn))
+	})
+}
+
+// buildDNSProvider 使用指定的凭据创建 cfg 选择的 DNSProvider，
+// account 是该凭据在限流器中的账号标识（AccessKey ID 或 RAM 角色所属账号）
+func (s *Solver) buildDNSProvider(cp providers.CredentialsProvider, cfg *Config, account string) (DNSProvider, error) {
+	cred := credential.FromCredentialsProvider(cp.GetProviderName(), cp)
+	switch cfg.Backend {
+	case backendPrivateZone:
+		return s.buildPrivateZoneProvider(cred, cfg.VpcID, account)
+	case backendMirror:
+		public, err := s.buildAliDNSProvider(cred, cfg.RegionID, account)
+		if err != nil {
+			return nil, err
+		}
+		private, err := s.buildPrivateZoneProvider(cred, cfg.VpcID, account)
+		if err != nil {
+			return nil, err
+		}
+		return newMirrorProvider(public, private, cfg.MirrorPolicy), nil
+	}
+	return s.buildAliDNSProvider(cred, cfg.RegionID, account)
+}
+
+// buildAliDNSProvider 使用指定的凭据创建公网 AliDNS 的 DNSProvider
+func (s *Solver) buildAliDNSProvider(cred credential.Credential, regionID, account string) (DNSProvider, error) {
+	if s.newDNSProvider != nil {
+		provider, err := s.newDNSProvider(cred, regionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create alidns client: %w", err)
+		}
+		return provider, nil
+	}
+
+	client, err := newAliDNSClient(cred, regionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alidns client: %w", err)
+	}
+	return s.newAliDNSProvider(s.limiter.wrap(client, account)), nil
+}
+
+// newAliDNSProvider 创建使用 Solver 共享最小 TTL 缓存的 dnsProvider
+func (s *Solver) newAliDNSProvider(client AliDNSClient) *dnsProvider {
+	provider := newDNSProviderWithClient(client)
+	provider.minTTLs = &s.minTTLs
+	return provider
+}
+
+// buildPrivateZoneProvider 使用指定的凭据创建 PrivateZone 的 DNSProvider
+func (s *Solver) buildPrivateZoneProvider(cred credential.Credential, vpcID, account string) (DNSProvider, error) {
+	client, err := newPrivateZoneClient(cred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pvtz client: %w", err)
+	}
+	return newPrivateZoneProvider(s.limiter.wrapPrivateZone(client, account), vpcID), nil
+}
+
+// ramRoleCredentialsProvider 在基础凭据之上扮演 cfg.RoleArn 指定的 RAM 角色
+func ramRoleCredentialsProvider(base providers.CredentialsProvider, cfg *Config) (providers.CredentialsProvider, error) {
+	sessionName := cfg.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+	cp, err := providers.NewRAMRoleARNCredentialsProviderBuilder().
+		WithCredentialsProvider(base).
+		WithRoleArn(cfg.RoleArn).
+		WithRoleSessionName(sessionName).
+		WithExternalId(cfg.ExternalId).
+		WithDurationSeconds(int(cfg.sessionDuration() / time.Second)).
+		Build()
+	if err != nil {
+		return nil, invalidConfigf("invalid role configuration for %s: %w", cfg.RoleArn, err)
+	}
+	return cp, nil
+}
+
+// credentialsFingerprint 返回静态凭据的摘要，用于缓存 key，避免在 key 中保存明文
+func credentialsFingerprint(cp providers.CredentialsProvider) (string, error) {
+	cc, err := cp.GetCredentials()
+	if err != nil {
+		return "", fmt.Errorf("failed to read credentials: %w", err)
+	}
+	sum := sha256.Sum256([]byte(cc.AccessKeyId + "\x00" + cc.AccessKeySecret + "\x00" + cc.SecurityToken))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// secretCredentialsProvider 从 Issuer 所在 namespace 的 Secret 中读取 AccessKey，
+// 配置了 SecurityToken 时返回 STS 凭据。同时返回 AccessKey ID 用于缓存标识。
+func (s *Solver) secretCredentialsProvider(ctx context.Context, cfg *Config, namespace string) (string, providers.CredentialsProvider, error) {
+	if cfg.AccessKeyIDSecretRef == nil || cfg.AccessKeySecretSecretRef == nil {
+		return "", nil, invalidConfigf("accessKeyIdSecretRef and accessKeySecretSecretRef must both be set")
+	}
+
+	accessKeyID, err := s.secretValue(ctx, namespace, cfg.AccessKeyIDSecretRef)
+	if err != nil {
+		return "", nil, err
+	}
+	accessKeySecret, err := s.secretValue(ctx, namespace, cfg.AccessKeySecretSecretRef)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if cfg.SecurityTokenSecretRef == nil {
+		cp, err := providers.NewStaticAKCredentialsProviderBuilder().
+			WithAccessKeyId(accessKeyID).
+			WithAccessKeySecret(accessKeySecret).
+			Build()
+		return accessKeyID, cp, err
+	}
+
+	securityToken, err := s.secretValue(ctx, namespace, cfg.SecurityTokenSecretRef)
+	if err != nil {
+		return "", nil, err
+	}
+	cp, err := providers.NewStaticSTSCredentialsProviderBuilder().
+		WithAccessKeyId(accessKeyID).
+		WithAccessKeySecret(accessKeySecret).
+		WithSecurityToken(securityToken).
+		Build()
+	return accessKeyID, cp, err
+}
+
+// secretValue 读取 Secret 中指定 key 的值
+func (s *Solver) secretValue(ctx context.Context, namespace string, ref *cmmeta.SecretKeySelector) (string, error) {
+	if s.kubeClient == nil {
+		return "", fmt.Errorf("kubernetes client not initialized")
+	}
+	if ref.Name == "" || ref.Key == "" {
+		return "", invalidConfigf("secret reference must set both name and key")
+	}
+
+	secret, err := s.kubeClient.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, ref.Name, err)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok || len(value) == 0 {
+		return "", fmt.Errorf("key %q not found in secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return string(value), nil
+}
+
+// providerCache 按 key 缓存 DNSProvider，每个条目在 ttl 后过期
+type providerCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedProvider
+	// now 用于测试中替换时钟
+	now func() time.Time
+}
+
+type cachedProvider struct {
+	provider  DNSProvider
+	expiresAt time.Time
+}
+
+// getOrCreate 返回未过期的缓存条目，否则调用 create 创建并缓存
+func (c *providerCache) getOrCreate(key string, ttl time.Duration, create func() (DNSProvider, error)) (DNSProvider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	if entry, ok := c.entries[key]; ok && now().Before(entry.expiresAt) {
+		return entry.provider, nil
+	}
+
+	provider, err := create()
+	if err != nil {
+		return nil, err
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]cachedProvider)
+	}
+	// 顺便清理已过期的条目
+	for k, entry := range c.entries {
+		if !now().Before(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedProvider{provider: provider, expiresAt: now().Add(ttl)}
+	return provider, nil
+}
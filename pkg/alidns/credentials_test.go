@@ -0,0 +1,438 @@
+package alidns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	credential "github.com/aliyun/credentials-go/credentials"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestSecret(namespace, name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Data:       map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func secretRef(name, key string) *cmmeta.SecretKeySelector {
+	return &cmmeta.SecretKeySelector{
+		LocalObjectReference: cmmeta.LocalObjectReference{Name: name},
+		Key:                  key,
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig(nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.hasSecretCredentials())
+
+	cfg, err = loadConfig(&extapi.JSON{Raw: []byte(`{
+		"accessKeyIdSecretRef": {"name": "alidns", "key": "id"},
+		"accessKeySecretSecretRef": {"name": "alidns", "key": "secret"}
+	}`)})
+	require.NoError(t, err)
+	assert.True(t, cfg.hasSecretCredentials())
+	assert.Equal(t, "alidns", cfg.AccessKeyIDSecretRef.Name)
+	assert.Equal(t, "secret", cfg.AccessKeySecretSecretRef.Key)
+	assert.Nil(t, cfg.SecurityTokenSecretRef)
+
+	_, err = loadConfig(&extapi.JSON{Raw: []byte(`{`)})
+	assert.Error(t, err)
+
+	cfg, err = loadConfig(&extapi.JSON{Raw: []byte(`{"backend": "pvtz", "vpcId": "vpc-a"}`)})
+	require.NoError(t, err)
+	assert.True(t, cfg.usesPrivateZone())
+	assert.Equal(t, "vpc-a", cfg.VpcID)
+
+	_, err = loadConfig(&extapi.JSON{Raw: []byte(`{"backend": "route53"}`)})
+	assert.ErrorContains(t, err, `invalid backend "route53"`)
+
+	_, err = loadConfig(&extapi.JSON{Raw: []byte(`{"backend": "mirror", "mirrorPolicy": "any"}`)})
+	assert.ErrorContains(t, err, `invalid mirrorPolicy "any"`)
+}
+
+func TestSolver_ProviderFor(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-a", "token": "sts-a"}),
+		newTestSecret("team-b", "alidns", map[string]string{"id": "ak-b"}),
+	)
+
+	tests := []struct {
+		name        string
+		cfg         *Config
+		namespace   string
+		expectType  string
+		expectAK    string
+		expectToken string
+		expectError string
+	}{
+		{
+			name:      "no credentials - ambient provider",
+			cfg:       &Config{},
+			namespace: "team-a",
+		},
+		{
+			name: "access key from secret",
+			cfg: &Config{
+				AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+				AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+			},
+			namespace:  "team-a",
+			expectType: "static_ak",
+			expectAK:   "ak-a",
+		},
+		{
+			name: "sts token from secret",
+			cfg: &Config{
+				AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+				AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+				SecurityTokenSecretRef:   secretRef("alidns", "token"),
+			},
+			namespace:   "team-a",
+			expectType:  "static_sts",
+			expectAK:    "ak-a",
+			expectToken: "sts-a",
+		},
+		{
+			name: "secret in another namespace is not visible",
+			cfg: &Config{
+				AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+				AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+			},
+			namespace:   "team-c",
+			expectError: "failed to get secret team-c/alidns",
+		},
+		{
+			name: "missing key",
+			cfg: &Config{
+				AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+				AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+			},
+			namespace:   "team-b",
+			expectError: `key "secret" not found in secret team-b/alidns`,
+		},
+		{
+			name: "incomplete config",
+			cfg: &Config{
+				AccessKeyIDSecretRef: secretRef("alidns", "id"),
+			},
+			namespace:   "team-a",
+			expectError: "accessKeyIdSecretRef and accessKeySecretSecretRef must both be set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ambient := &MockDNSProvider{}
+			var gotCredential credential.Credential
+			solver := &Solver{
+				kubeClient:  kubeClient,
+				dnsProvider: ambient,
+				newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+					gotCredential = cred
+					return &MockDNSProvider{}, nil
+				},
+			}
+
+			provider, err := solver.providerFor(context.Background(), tt.cfg, tt.namespace)
+			if tt.expectError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.expectType == "" {
+				assert.Same(t, ambient, provider)
+				assert.Nil(t, gotCredential)
+				return
+			}
+			assert.NotSame(t, ambient, provider)
+			require.NotNil(t, gotCredential)
+			model, err := gotCredential.GetCredential()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectType, *model.Type)
+			assert.Equal(t, tt.expectAK, *model.AccessKeyId)
+			assert.Equal(t, tt.expectToken, *model.SecurityToken)
+		})
+	}
+}
+
+func TestSolver_Present_SecretCredentials(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-a"}),
+	)
+	issuerProvider := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "issuer-record-id", nil
+		},
+	}
+	solver := &Solver{
+		kubeClient: kubeClient,
+		dnsProvider: &MockDNSProvider{
+			AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+				t.Fatal("ambient provider must not be used when issuer credentials are configured")
+				return "", nil
+			},
+		},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			return issuerProvider, nil
+		},
+	}
+
+	ch := &v1alpha1.ChallengeRequest{
+		ResolvedFQDN:      "_acme-challenge.example.com.",
+		ResolvedZone:      "example.com.",
+		Key:               "test-key-value",
+		ResourceNamespace: "team-a",
+		Config: &extapi.JSON{Raw: []byte(`{
+			"accessKeyIdSecretRef": {"name": "alidns", "key": "id"},
+			"accessKeySecretSecretRef": {"name": "alidns", "key": "secret"}
+		}`)},
+	}
+
+	assert.NoError(t, solver.Present(ch))
+	assert.NoError(t, solver.CleanUp(ch))
+}
+
+func TestSolver_ProviderFor_RoleArn(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-a"}),
+	)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := 0
+	var gotCredential credential.Credential
+	solver := &Solver{
+		kubeClient:  kubeClient,
+		dnsProvider: &MockDNSProvider{},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			created++
+			gotCredential = cred
+			return &MockDNSProvider{}, nil
+		},
+		roleProviders: providerCache{now: func() time.Time { return now }},
+	}
+
+	cfg := &Config{
+		AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+		AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+		RoleArn:                  "acs:ram::1234567890:role/dns-admin",
+		ExternalId:               "team-a",
+		SessionDuration:          900,
+	}
+
+	first, err := solver.providerFor(context.Background(), cfg, "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "ram_role_arn", *gotCredential.GetType())
+	assert.Equal(t, 1, created)
+
+	// 同一角色在 STS Token 过期前复用缓存
+	second, err := solver.providerFor(context.Background(), cfg, "team-a")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, created)
+
+	// 不同角色使用不同的缓存条目
+	other := *cfg
+	other.RoleArn = "acs:ram::1234567890:role/other"
+	_, err = solver.providerFor(context.Background(), &other, "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+
+	// 过期后重新创建
+	now = now.Add(15 * time.Minute)
+	third, err := solver.providerFor(context.Background(), cfg, "team-a")
+	require.NoError(t, err)
+	assert.NotSame(t, first, third)
+	assert.Equal(t, 3, created)
+}
+
+func TestSolver_ProviderFor_RoleArnForgedSecret(t *testing.T) {
+	// 其他 Issuer 只知道 AccessKey ID，不能复用已扮演角色的 DNSProvider
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-a"}),
+		newTestSecret("team-a", "forged", map[string]string{"id": "ak-a", "secret": "made-up"}),
+		newTestSecret("team-b", "alidns", map[string]string{"id": "ak-a", "secret": "made-up"}),
+	)
+	solver := &Solver{
+		kubeClient:  kubeClient,
+		dnsProvider: &MockDNSProvider{},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			return &MockDNSProvider{}, nil
+		},
+	}
+	cfg := func(secret string) *Config {
+		return &Config{
+			AccessKeyIDSecretRef:     secretRef(secret, "id"),
+			AccessKeySecretSecretRef: secretRef(secret, "secret"),
+			RoleArn:                  "acs:ram::1234567890:role/dns-admin",
+			ExternalId:               "team-a",
+		}
+	}
+	ctx := context.Background()
+
+	owner, err := solver.providerFor(ctx, cfg("alidns"), "team-a")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		secret    string
+		namespace string
+	}{
+		{name: "different secret in the same namespace", secret: "forged", namespace: "team-a"},
+		{name: "different namespace", secret: "alidns", namespace: "team-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := solver.providerFor(ctx, cfg(tt.secret), tt.namespace)
+			require.NoError(t, err)
+			assert.NotSame(t, owner, provider)
+		})
+	}
+}
+
+func TestSolver_ProviderFor_SecretCached(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-a"}),
+	)
+	created := 0
+	solver := &Solver{
+		kubeClient:  kubeClient,
+		dnsProvider: &MockDNSProvider{},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			created++
+			return &MockDNSProvider{}, nil
+		},
+	}
+	cfg := &Config{
+		AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+		AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+	}
+	ctx := context.Background()
+
+	// 相同的 Secret 凭据复用同一个 DNSProvider
+	first, err := solver.providerFor(ctx, cfg, "team-a")
+	require.NoError(t, err)
+	second, err := solver.providerFor(ctx, cfg, "team-a")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, created)
+
+	// 不同地域使用不同的缓存条目
+	other := *cfg
+	other.RegionID = "cn-shanghai"
+	_, err = solver.providerFor(ctx, &other, "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+
+	// 轮换 AccessKey Secret 后重新创建
+	_, err = kubeClient.CoreV1().Secrets("team-a").Update(ctx,
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-b"}), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	rotated, err := solver.providerFor(ctx, cfg, "team-a")
+	require.NoError(t, err)
+	assert.NotSame(t, first, rotated)
+	assert.Equal(t, 3, created)
+}
+
+func TestSolver_ProviderFor_PrivateZone(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("team-a", "alidns", map[string]string{"id": "ak-a", "secret": "sk-a"}),
+	)
+	ambient := &MockDNSProvider{}
+	solver := &Solver{
+		kubeClient:  kubeClient,
+		dnsProvider: ambient,
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			t.Fatal("pvtz backend should not create an AliDNS provider")
+			return nil, nil
+		},
+	}
+
+	// webhook 自身的凭据：按 VPC 缓存
+	provider, err := solver.providerFor(context.Background(), &Config{Backend: backendPrivateZone, VpcID: "vpc-a"}, "team-a")
+	require.NoError(t, err)
+	pvtz, ok := provider.(*privateZoneProvider)
+	require.True(t, ok)
+	assert.Equal(t, "vpc-a", pvtz.vpcID)
+
+	again, err := solver.providerFor(context.Background(), &Config{Backend: backendPrivateZone, VpcID: "vpc-a"}, "team-a")
+	require.NoError(t, err)
+	assert.Same(t, provider, again)
+
+	other, err := solver.providerFor(context.Background(), &Config{Backend: backendPrivateZone, VpcID: "vpc-b"}, "team-a")
+	require.NoError(t, err)
+	assert.NotSame(t, provider, other)
+
+	// Secret 中的凭据
+	provider, err = solver.providerFor(context.Background(), &Config{
+		Backend:                  backendPrivateZone,
+		AccessKeyIDSecretRef:     secretRef("alidns", "id"),
+		AccessKeySecretSecretRef: secretRef("alidns", "secret"),
+	}, "team-a")
+	require.NoError(t, err)
+	assert.IsType(t, &privateZoneProvider{}, provider)
+
+	// 默认 backend 仍使用 AliDNS
+	provider, err = solver.providerFor(context.Background(), &Config{Backend: backendAliDNS}, "team-a")
+	require.NoError(t, err)
+	assert.Same(t, ambient, provider)
+}
+
+func TestSolver_ProviderFor_Mirror(t *testing.T) {
+	public := &MockDNSProvider{}
+	solver := &Solver{
+		dnsProvider: &MockDNSProvider{},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			return public, nil
+		},
+	}
+
+	provider, err := solver.providerFor(context.Background(), &Config{Backend: backendMirror, VpcID: "vpc-a", MirrorPolicy: mirrorPolicyPublic}, "team-a")
+	require.NoError(t, err)
+	mirror, ok := provider.(*mirrorProvider)
+	require.True(t, ok)
+	assert.False(t, mirror.requireAll)
+	assert.Same(t, public, mirror.public)
+	private, ok := mirror.private.(*privateZoneProvider)
+	require.True(t, ok)
+	assert.Equal(t, "vpc-a", private.vpcID)
+
+	// 不同的失败策略使用不同的缓存
+	other, err := solver.providerFor(context.Background(), &Config{Backend: backendMirror, VpcID: "vpc-a"}, "team-a")
+	require.NoError(t, err)
+	assert.NotSame(t, provider, other)
+	assert.True(t, other.(*mirrorProvider).requireAll)
+}
+
+func TestSolver_ProviderFor_RoleArnWithAmbientCredentials(t *testing.T) {
+	var gotCredential credential.Credential
+	solver := &Solver{
+		dnsProvider: &MockDNSProvider{},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			gotCredential = cred
+			return &MockDNSProvider{}, nil
+		},
+	}
+
+	_, err := solver.providerFor(context.Background(), &Config{RoleArn: "acs:ram::1234567890:role/dns-admin"}, "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "ram_role_arn", *gotCredential.GetType())
+
+	_, err = solver.providerFor(context.Background(), &Config{RoleArn: "acs:ram::1234567890:role/dns-admin", SessionDuration: 60}, "team-a")
+	assert.ErrorContains(t, err, "session duration")
+}
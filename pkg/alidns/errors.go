@@ -0,0 +1,214 @@
+package alidns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/dara"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// 可以用 errors.Is 判断的错误类型，阿里云 API 返回的错误会被包装为 *APIError
+var (
+	// ErrZoneNotFound 表示域名或 PrivateZone 不在当前凭据所属的账号下
+	ErrZoneNotFound = errors.New("zone not found")
+	// ErrPermissionDenied 表示凭据没有调用该 API 的 RAM 权限
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrThrottled 表示请求被阿里云限流
+	ErrThrottled = errors.New("throttled")
+	// ErrQuotaExceeded 表示域名的记录数等配额已用完
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInvalidCredentials 表示 AccessKey、STS Token 无效或已过期
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// errInvalidConfig 表示 solver 配置无效，修改 Issuer 配置前重试不会成功
+var errInvalidConfig = errors.New("invalid solver config")
+
+// configError 包装 solver 配置错误，错误信息不变，可以用 errors.Is(err, errInvalidConfig) 判断
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+func (e *configError) Unwrap() []error {
+	return []error{errInvalidConfig, e.err}
+}
+
+// invalidConfigf 按 fmt.Errorf 的格式创建配置错误
+func invalidConfigf(format string, args ...interface{}) error {
+	return &configError{err: fmt.Errorf(format, args...)}
+}
+
+// APIError 是阿里云 API 返回的错误，保留了排查问题需要的 RequestId 和诊断链接。
+// errors.Is 可以匹配 Kind，errors.As 可以取出 SDK 原始的错误
+type APIError struct {
+	// Kind 是错误类型，例如 ErrPermissionDenied，无法归类时为 nil
+	Kind error
+	// Action 是调用的 API，例如 AddDomainRecord
+	Action     string
+	Code       string
+	StatusCode int
+	Message    string
+	RequestID  string
+	// Recommend 是阿里云 API 诊断页面的链接
+	Recommend string
+	// Err 是 SDK 返回的原始错误
+	Err error
+}
+
+func (e *APIError) Error() string {
+	msg := e.Code
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request id " + e.RequestID + ")"
+	}
+	if e.Recommend != "" {
+		msg += ", see " + e.Recommend
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// sdkError 是不同版本 SDK 错误中的公共字段
+type sdkError struct {
+	code       string
+	statusCode int
+	message    string
+	// data 是 API 返回的错误响应，包含 Message、RequestId、Recommend 等字段
+	data map[string]interface{}
+}
+
+// asSDKError 从 err 中取出 SDK 错误。
+// tea 的 SDKError 和 darabonba-openapi v2 的 ClientError、ServerError、ThrottlingError 都会被识别
+func asSDKError(err error) (sdkError, bool) {
+	var teaErr *tea.SDKError
+	if errors.As(err, &teaErr) {
+		return sdkError{
+			code:       tea.StringValue(teaErr.Code),
+			statusCode: tea.IntValue(teaErr.StatusCode),
+			message:    tea.StringValue(teaErr.Message),
+			data:       decodeErrorData(tea.StringValue(teaErr.Data)),
+		}, true
+	}
+
+	var daraErr *dara.SDKError
+	if errors.As(err, &daraErr) {
+		return sdkError{
+			code:       dara.StringValue(daraErr.Code),
+			statusCode: dara.IntValue(daraErr.StatusCode),
+			message:    dara.StringValue(daraErr.Message),
+			data:       decodeErrorData(dara.StringValue(daraErr.Data)),
+		}, true
+	}
+
+	var respErr dara.ResponseError
+	if errors.As(err, &respErr) {
+		return sdkError{
+			code:       dara.StringValue(respErr.GetCode()),
+			statusCode: dara.IntValue(respErr.GetStatusCode()),
+			message:    respErr.Error(),
+			data:       respErr.GetData(),
+		}, true
+	}
+	return sdkError{}, false
+}
+
+// decodeErrorData 解析 SDKError.Data 中 JSON 格式的错误响应
+func decodeErrorData(data string) map[string]interface{} {
+	decoded := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(data), &decoded)
+	return decoded
+}
+
+// field 返回错误响应中的字符串字段
+func (e sdkError) field(key string) string {
+	if value, ok := e.data[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// errorKind 按错误码返回错误类型
+func errorKind(code string) error {
+	switch {
+	case strings.HasPrefix(code, "InvalidAccessKeyId"), strings.HasPrefix(code, "InvalidSecurityToken"),
+		code == "SignatureDoesNotMatch", code == "IncompleteSignature":
+		return ErrInvalidCredentials
+	case strings.HasPrefix(code, "Forbidden"), code == "NoPermission":
+		return ErrPermissionDenied
+	case strings.HasPrefix(code, "Throttling"):
+		return ErrThrottled
+	case strings.HasPrefix(code, "QuotaExceeded"), strings.HasSuffix(code, "LimitExceeded"):
+		return ErrQuotaExceeded
+	case code == "InvalidDomainName.NoExist", code == "IncorrectDomainUser", code == "Zone.NotExists":
+		return ErrZoneNotFound
+	}
+	return nil
+}
+
+// newAPIError 将 SDK 返回的错误包装为 *APIError，其他错误原样返回
+func newAPIError(action string, err error) error {
+	sdkErr, ok := asSDKError(err)
+	if !ok {
+		return err
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	message := sdkErr.field("Message")
+	if message == "" {
+		message = sdkErr.message
+	}
+	return &APIError{
+		Kind:       errorKind(sdkErr.code),
+		Action:     action,
+		Code:       sdkErr.code,
+		StatusCode: sdkErr.statusCode,
+		Message:    message,
+		RequestID:  sdkErr.field("RequestId"),
+		Recommend:  sdkErr.field("Recommend"),
+		Err:        err,
+	}
+}
+
+// actionableError 为 Present 返回的错误补充处理建议，
+// cert-manager 会将其写入 Challenge 的 status 中
+func actionableError(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	var hint string
+	switch apiErr.Kind {
+	case ErrZoneNotFound:
+		hint = "add the zone to the Alibaba Cloud account the Issuer's credentials belong to"
+	case ErrPermissionDenied:
+		hint = fmt.Sprintf("grant %s to the RAM user or role used by the Issuer", apiErr.Action)
+	case ErrThrottled:
+		hint = "the API is throttling requests; lower the webhook's rate limit or wait for the next retry"
+	case ErrQuotaExceeded:
+		hint = "delete unused records or upgrade the domain's edition to raise the record quota"
+	case ErrInvalidCredentials:
+		hint = "check the AccessKey, STS token or RAM role configured for the Issuer"
+	default:
+		return err
+	}
+	return fmt.Errorf("%w; %s", err, hint)
+}
@@ -0,0 +1,143 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOpenAPIError 构造 darabonba-openapi v2 客户端对 4xx 响应返回的错误
+func newOpenAPIError(code string, statusCode int) error {
+	return &openapi.ClientError{
+		StatusCode: tea.Int(statusCode),
+		Code:       tea.String(code),
+		Message:    tea.String(fmt.Sprintf("code: %d, denied request id: req-1", statusCode)),
+		RequestId:  tea.String("req-1"),
+		Data: map[string]interface{}{
+			"Code":      code,
+			"Message":   "denied",
+			"RequestId": "req-1",
+			"Recommend": "https://api.aliyun.com/troubleshoot?q=" + code,
+		},
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expectKind error
+	}{
+		{name: "ram forbidden", err: newOpenAPIError("Forbidden.RAM", 403), expectKind: ErrPermissionDenied},
+		{name: "invalid access key", err: newOpenAPIError("InvalidAccessKeyId.NotFound", 404), expectKind: ErrInvalidCredentials},
+		{name: "expired token", err: newOpenAPIError("InvalidSecurityToken.Expired", 400), expectKind: ErrInvalidCredentials},
+		{name: "signature", err: newOpenAPIError("SignatureDoesNotMatch", 400), expectKind: ErrInvalidCredentials},
+		{name: "throttling", err: &openapi.ThrottlingError{Code: tea.String("Throttling.User"), StatusCode: tea.Int(400)}, expectKind: ErrThrottled},
+		{name: "record quota", err: newOpenAPIError("QuotaExceeded.Record", 400), expectKind: ErrQuotaExceeded},
+		{name: "domain not in account", err: newOpenAPIError("IncorrectDomainUser", 400), expectKind: ErrZoneNotFound},
+		{name: "private zone missing", err: newOpenAPIError("Zone.NotExists", 400), expectKind: ErrZoneNotFound},
+		{name: "tea sdk error", err: newSDKError("Forbidden.RAM", 403), expectKind: ErrPermissionDenied},
+		{name: "unclassified", err: newOpenAPIError("InvalidParameter", 400)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newAPIError("AddDomainRecord", fmt.Errorf("wrapped: %w", tt.err))
+
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, "AddDomainRecord", apiErr.Action)
+			assert.Equal(t, tt.expectKind, apiErr.Kind)
+			if tt.expectKind != nil {
+				assert.ErrorIs(t, err, tt.expectKind)
+			}
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewAPIError_Fields(t *testing.T) {
+	err := newAPIError("AddDomainRecord", newOpenAPIError("Forbidden.RAM", 403))
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Forbidden.RAM", apiErr.Code)
+	assert.Equal(t, 403, apiErr.StatusCode)
+	assert.Equal(t, "denied", apiErr.Message)
+	assert.Equal(t, "req-1", apiErr.RequestID)
+	assert.Equal(t, "https://api.aliyun.com/troubleshoot?q=Forbidden.RAM", apiErr.Recommend)
+	assert.Equal(t, "Forbidden.RAM: denied (request id req-1), see https://api.aliyun.com/troubleshoot?q=Forbidden.RAM", err.Error())
+
+	// tea 的 SDKError 中 Data 为 JSON 字符串
+	err = newAPIError("DeleteDomainRecord", tea.NewSDKError(map[string]interface{}{
+		"code":    "Forbidden.RAM",
+		"message": "code: 403, denied request id: req-2",
+		"data":    map[string]interface{}{"statusCode": 403, "Message": "denied", "RequestId": "req-2"},
+	}))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 403, apiErr.StatusCode)
+	assert.Equal(t, "denied", apiErr.Message)
+	assert.Equal(t, "req-2", apiErr.RequestID)
+	assert.Empty(t, apiErr.Recommend)
+
+	// 非 SDK 错误原样返回
+	plain := errors.New("connection reset")
+	assert.Same(t, plain, newAPIError("AddDomainRecord", plain))
+	assert.NoError(t, newAPIError("AddDomainRecord", nil))
+}
+
+func TestCallWithRetry_APIError(t *testing.T) {
+	_, err := callWithRetry(context.Background(), "AddDomainRecord", func(runtime *util.RuntimeOptions) (string, error) {
+		return "", newOpenAPIError("Forbidden.RAM", 403)
+	})
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	assert.True(t, isErrorCode(err, "Forbidden.RAM"))
+	assert.False(t, isRetryable(err))
+
+	// darabonba-openapi v2 的限流错误同样会重试
+	assert.True(t, isRetryable(&openapi.ThrottlingError{Code: tea.String("Throttling.User"), StatusCode: tea.Int(400)}))
+	assert.True(t, isRetryable(&openapi.ServerError{Code: tea.String("InternalError"), StatusCode: tea.Int(500)}))
+}
+
+func TestActionableError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		expect string
+	}{
+		{name: "permission denied", err: newOpenAPIError("Forbidden.RAM", 403), expect: "grant AddDomainRecord to the RAM user or role used by the Issuer"},
+		{name: "invalid credentials", err: newOpenAPIError("InvalidAccessKeyId.NotFound", 404), expect: "check the AccessKey, STS token or RAM role"},
+		{name: "quota", err: newOpenAPIError("QuotaExceeded.Record", 400), expect: "raise the record quota"},
+		{name: "unclassified", err: newOpenAPIError("InvalidParameter", 400), expect: "InvalidParameter: denied (request id req-1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := actionableError(fmt.Errorf("failed to add domain record: %w", newAPIError("AddDomainRecord", tt.err)))
+			assert.ErrorContains(t, err, "failed to add domain record: ")
+			assert.ErrorContains(t, err, tt.expect)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	plain := errors.New("mock api error")
+	assert.Same(t, plain, actionableError(plain))
+}
+
+func TestInvalidConfigf(t *testing.T) {
+	cause := errors.New("unknown backend")
+	err := invalidConfigf("failed to load config: %w", cause)
+
+	// 错误信息不变，同时可以识别为配置错误和原始错误
+	assert.Equal(t, "failed to load config: unknown backend", err.Error())
+	assert.ErrorIs(t, err, errInvalidConfig)
+	assert.ErrorIs(t, err, cause)
+	assert.ErrorIs(t, fmt.Errorf("cleanup: %w", err), errInvalidConfig)
+}
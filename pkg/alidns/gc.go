@@ -0,0 +1,222 @@
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/cert-manager/cert-manager/pkg/issuer/acme/dns/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// challengeRRPrefix 是 ACME DNS-01 challenge 记录的主机记录前缀
+	challengeRRPrefix = "_acme-challenge"
+	// defaultGCInterval 是两次扫描之间的默认间隔
+	defaultGCInterval = time.Hour
+	// defaultGCMaxAge 是默认的记录最长保留时间，远大于一次 challenge 的耗时
+	defaultGCMaxAge = 24 * time.Hour
+	// gcLeaseName 是 GC 选主使用的 Lease 名称
+	gcLeaseName = leaseNamePrefix + "gc"
+)
+
+// gcConfig 是孤儿 challenge 记录回收的配置
+type gcConfig struct {
+	// zones 是需要扫描的域名，为空时不启用回收
+	zones []string
+	// interval 是两次扫描之间的间隔
+	interval time.Duration
+	// maxAge 是记录创建后超过多久视为孤儿记录
+	maxAge time.Duration
+	// dryRun 为 true 时只输出日志，不删除记录
+	dryRun bool
+}
+
+// gcConfigFromEnv 从环境变量读取回收配置：
+// ALIDNS_GC_ZONES（逗号分隔）、ALIDNS_GC_INTERVAL、ALIDNS_GC_MAX_AGE、ALIDNS_GC_DRY_RUN
+func gcConfigFromEnv() (gcConfig, error) {
+	cfg := gcConfig{
+		interval: defaultGCInterval,
+		maxAge:   defaultGCMaxAge,
+	}
+	for _, zone := range strings.Split(os.Getenv("ALIDNS_GC_ZONES"), ",") {
+		if zone = util.UnFqdn(strings.TrimSpace(zone)); zone != "" {
+			cfg.zones = append(cfg.zones, zone)
+		}
+	}
+
+	var err error
+	if cfg.interval, err = durationEnv("ALIDNS_GC_INTERVAL", cfg.interval); err != nil {
+		return gcConfig{}, err
+	}
+	if cfg.maxAge, err = durationEnv("ALIDNS_GC_MAX_AGE", cfg.maxAge); err != nil {
+		return gcConfig{}, err
+	}
+	if value := os.Getenv("ALIDNS_GC_DRY_RUN"); value != "" {
+		if cfg.dryRun, err = strconv.ParseBool(value); err != nil {
+			return gcConfig{}, fmt.Errorf("invalid ALIDNS_GC_DRY_RUN %q: %w", value, err)
+		}
+	}
+	return cfg, nil
+}
+
+// durationEnv 读取正的时长环境变量，未设置时返回 def
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive duration", name, value)
+	}
+	return d, nil
+}
+
+// recordScanner 由支持按 zone 扫描记录的 DNSProvider 实现
+type recordScanner interface {
+	DescribeRecords(ctx context.Context, domain, rr string) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error)
+}
+
+// runGC 启动孤儿记录回收。配置了 Kubernetes 客户端时通过 Lease 选主，
+// 只有 leader 执行扫描，直到 stopCh 关闭
+func (s *Solver) runGC(cfg gcConfig, identity string, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	if s.kubeClient == nil || s.namespace == "" {
+		s.sweepLoop(ctx, cfg)
+		return
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: gcLeaseName, Namespace: s.namespace},
+		Client:     s.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   leaseDuration,
+			RenewDeadline:   leaseDuration * 2 / 3,
+			RetryPeriod:     leaseDuration / 5,
+			ReleaseOnCancel: true,
+			Name:            gcLeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					slog.Info("Started leading record garbage collection", "identity", identity)
+					s.sweepLoop(ctx, cfg)
+				},
+				OnStoppedLeading: func() {
+					slog.Info("Stopped leading record garbage collection", "identity", identity)
+				},
+			},
+		})
+	}
+}
+
+// sweepLoop 立即扫描一次，之后每隔 cfg.interval 扫描，直到 ctx 取消
+func (s *Solver) sweepLoop(ctx context.Context, cfg gcConfig) {
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+	for {
+		for _, zone := range cfg.zones {
+			if err := s.sweepZone(ctx, zone, cfg); err != nil {
+				slog.Warn("Failed to collect orphaned challenge records", "zone", zone, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepZone 删除 zone 中本 webhook 创建且超过 cfg.maxAge 的 challenge 记录
+func (s *Solver) sweepZone(parent context.Context, zone string, cfg gcConfig) error {
+	ctx, cancel := context.WithTimeout(parent, operationTimeout)
+	defer cancel()
+
+	provider, err := s.zoneProvider(ctx, zone)
+	if err != nil {
+		return err
+	}
+	scanner, ok := provider.(recordScanner)
+	if !ok {
+		return fmt.Errorf("provider for zone %s does not support scanning records", zone)
+	}
+	records, err := scanner.DescribeRecords(ctx, zone, challengeRRPrefix)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deleted := 0
+	for _, record := range records {
+		rr := tea.StringValue(record.RR)
+		if rr != challengeRRPrefix && !strings.HasPrefix(rr, challengeRRPrefix+".") {
+			continue
+		}
+		marker, ok := parseRecordMarker(tea.StringValue(record.Remark))
+		if !ok || marker.Owner != s.ownership.owner {
+			continue
+		}
+		created := marker.Created
+		if created.IsZero() && record.CreateTimestamp != nil {
+			created = time.UnixMilli(*record.CreateTimestamp)
+		}
+		if created.IsZero() || now.Sub(created) < cfg.maxAge {
+			continue
+		}
+
+		recordId := tea.StringValue(record.RecordId)
+		if cfg.dryRun {
+			slog.Info("Would delete orphaned challenge record (dry run)",
+				"domain", zone, "rr", rr, "recordId", recordId, "name", marker.Name, "created", created)
+			continue
+		}
+		if err := s.deleteOrphanedRecord(ctx, provider, zone, rr, tea.StringValue(record.Value), recordId); err != nil {
+			slog.Warn("Failed to delete orphaned challenge record",
+				"domain", zone, "rr", rr, "recordId", recordId, "error", err)
+			continue
+		}
+		deleted++
+		slog.Info("Deleted orphaned challenge record",
+			"domain", zone, "rr", rr, "recordId", recordId, "name", marker.Name, "created", created)
+	}
+
+	slog.Debug("Collected orphaned challenge records", "zone", zone, "scanned", len(records), "deleted", deleted)
+	return nil
+}
+
+// deleteOrphanedRecord 在记录锁内按 ID 删除记录，避免与同名记录上进行中的 Present 冲突
+func (s *Solver) deleteOrphanedRecord(ctx context.Context, provider DNSProvider, domain, rr, value, recordId string) error {
+	unlock, err := s.lockRecord(ctx, domain, rr, value)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return provider.DeleteRecord(ctx, recordId)
+}
+
+// zoneProvider 返回管理 zone 的 DNSProvider：路由表中有匹配的条目时使用其凭据，
+// 否则使用 webhook 自身的凭据
+func (s *Solver) zoneProvider(ctx context.Context, zone string) (DNSProvider, error) {
+	if route := s.routes.match(zone); route != nil {
+		return s.providerFor(ctx, &route.Config, s.namespace)
+	}
+	return s.providerFor(ctx, &Config{}, s.namespace)
+}
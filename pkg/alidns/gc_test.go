@@ -0,0 +1,148 @@
+package alidns
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGCConfigFromEnv(t *testing.T) {
+	mustUnsetEnv(t, "ALIDNS_GC_ZONES")
+	mustUnsetEnv(t, "ALIDNS_GC_INTERVAL")
+	mustUnsetEnv(t, "ALIDNS_GC_MAX_AGE")
+	mustUnsetEnv(t, "ALIDNS_GC_DRY_RUN")
+
+	cfg, err := gcConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, gcConfig{interval: defaultGCInterval, maxAge: defaultGCMaxAge}, cfg)
+
+	mustSetEnv(t, "ALIDNS_GC_ZONES", "example.com, example.org.,")
+	mustSetEnv(t, "ALIDNS_GC_INTERVAL", "10m")
+	mustSetEnv(t, "ALIDNS_GC_MAX_AGE", "6h")
+	mustSetEnv(t, "ALIDNS_GC_DRY_RUN", "true")
+	cfg, err = gcConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, gcConfig{
+		zones:    []string{"example.com", "example.org"},
+		interval: 10 * time.Minute,
+		maxAge:   6 * time.Hour,
+		dryRun:   true,
+	}, cfg)
+
+	for name, value := range map[string]string{
+		"ALIDNS_GC_INTERVAL": "soon",
+		"ALIDNS_GC_MAX_AGE":  "-1h",
+		"ALIDNS_GC_DRY_RUN":  "maybe",
+	} {
+		t.Run(name, func(t *testing.T) {
+			mustSetEnv(t, name, value)
+			_, err := gcConfigFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+// newGCTestClient 返回 zone 中包含 records 的 mock 客户端，并记录被删除的记录 ID
+func newGCTestClient(records []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord) (*MockAliDNSClient, func() []string) {
+	var mu sync.Mutex
+	var deleted []string
+	client := &MockAliDNSClient{
+		DescribeDomainRecordsFunc: func(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error) {
+			return &alidns.DescribeDomainRecordsResponse{
+				Body: &alidns.DescribeDomainRecordsResponseBody{
+					TotalCount:    tea.Int64(int64(len(records))),
+					DomainRecords: &alidns.DescribeDomainRecordsResponseBodyDomainRecords{Record: records},
+				},
+			}, nil
+		},
+		DeleteDomainRecordFunc: func(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			deleted = append(deleted, *request.RecordId)
+			return &alidns.DeleteDomainRecordResponse{}, nil
+		},
+	}
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), deleted...)
+	}
+}
+
+func TestSolver_SweepZone(t *testing.T) {
+	now := time.Now()
+	remark := func(owner string, created time.Time) *string {
+		return tea.String(recordMarker{Owner: owner, Name: "_acme-challenge.example.com", Created: created}.String())
+	}
+	records := []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		{RecordId: tea.String("orphaned"), RR: tea.String("_acme-challenge"), Value: tea.String("a"), Remark: remark("cluster-a", now.Add(-48*time.Hour))},
+		{RecordId: tea.String("orphaned-nested"), RR: tea.String("_acme-challenge.www"), Value: tea.String("b"), Remark: remark("cluster-a", now.Add(-48*time.Hour))},
+		{RecordId: tea.String("recent"), RR: tea.String("_acme-challenge"), Value: tea.String("c"), Remark: remark("cluster-a", now.Add(-time.Hour))},
+		{RecordId: tea.String("other-owner"), RR: tea.String("_acme-challenge"), Value: tea.String("d"), Remark: remark("cluster-b", now.Add(-48*time.Hour))},
+		{RecordId: tea.String("manual"), RR: tea.String("_acme-challenge"), Value: tea.String("e"), CreateTimestamp: tea.Int64(now.Add(-48 * time.Hour).UnixMilli())},
+		{RecordId: tea.String("other-rr"), RR: tea.String("_acme-challenge-test"), Value: tea.String("f"), Remark: remark("cluster-a", now.Add(-48*time.Hour))},
+	}
+
+	tests := []struct {
+		name          string
+		dryRun        bool
+		expectDeleted []string
+	}{
+		{name: "delete orphaned records", expectDeleted: []string{"orphaned", "orphaned-nested"}},
+		{name: "dry run", dryRun: true, expectDeleted: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, deleted := newGCTestClient(records)
+			solver := NewSolver(newDNSProviderWithClient(client))
+			solver.ownership = ownershipConfig{owner: "cluster-a"}
+
+			cfg := gcConfig{zones: []string{"example.com"}, interval: time.Hour, maxAge: 24 * time.Hour, dryRun: tt.dryRun}
+			require.NoError(t, solver.sweepZone(t.Context(), "example.com", cfg))
+			assert.Equal(t, tt.expectDeleted, deleted())
+		})
+	}
+}
+
+func TestSolver_RunGC_LeaderElection(t *testing.T) {
+	records := []*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		{
+			RecordId: tea.String("orphaned"),
+			RR:       tea.String("_acme-challenge"),
+			Value:    tea.String("a"),
+			Remark:   tea.String(recordMarker{Owner: defaultOwnerID, Created: time.Now().Add(-48 * time.Hour)}.String()),
+		},
+	}
+	client, deleted := newGCTestClient(records)
+	solver := NewSolver(newDNSProviderWithClient(client))
+	solver.kubeClient = fake.NewSimpleClientset()
+	solver.namespace = "cert-manager"
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		solver.runGC(gcConfig{zones: []string{"example.com"}, interval: time.Hour, maxAge: time.Hour}, "pod-a", stopCh)
+	}()
+
+	// 成为 leader 后立即扫描一次
+	require.Eventually(t, func() bool { return len(deleted()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	_, err := solver.kubeClient.CoordinationV1().Leases("cert-manager").Get(t.Context(), gcLeaseName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runGC did not stop after stopCh was closed")
+	}
+}
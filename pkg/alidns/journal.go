@@ -0,0 +1,192 @@
+package alidns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/cert-manager/cert-manager/pkg/issuer/acme/dns/util"
+	"k8s.io/client-go/kubernetes"
+)
+
+// journalRetention 是 journal 条目的最长保留时间，超过后视为 CleanUp 不会再来
+const journalRetention = 7 * 24 * time.Hour
+
+// journalEntry 记录 Present 为某个 challenge 创建的 TXT 记录，配置了多个线路时每个线路一条
+type journalEntry struct {
+	Domain    string    `json:"domain"`
+	RR        string    `json:"rr"`
+	Value     string    `json:"value"`
+	RecordIDs []string  `json:"recordIds"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Challenge 保存 Present 收到的 challenge，Challenge 被删除后用于清理记录
+	Challenge *challengeSnapshot `json:"challenge,omitempty"`
+}
+
+// journalKey 返回 challenge 在 journal 中的 key。
+// cert-manager 不会设置 ChallengeRequest.UID，因此按 DNSName 和 Key 标识 challenge，
+// 与 Challenge 资源的 spec.dnsName、spec.key 对应；DNSName 为空时使用 ResolvedFQDN
+func journalKey(ch *v1alpha1.ChallengeRequest) string {
+	name := ch.DNSName
+	if name == "" {
+		name = util.UnFqdn(ch.ResolvedFQDN)
+	}
+	return challengeKey(name, ch.Key)
+}
+
+// challengeKey 返回 dnsName 和 key 的摘要，可以直接用作 ConfigMap 的 key
+func challengeKey(dnsName, key string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(dnsName) + "\x00" + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// challengeJournal 以 journalKey 为 key 保存 journalEntry，
+// 使 CleanUp 可以直接按记录 ID 删除。
+// 配置了 ConfigMap 时同时持久化，webhook 重启或由其他副本处理 CleanUp 时仍能找到记录。
+// 零值只保存在内存中，nil 时所有操作为空操作
+type challengeJournal struct {
+	mu      sync.Mutex
+	entries map[string]journalEntry
+
+	// store 是持久化使用的 ConfigMap，为 nil 时不持久化
+	store *configMapStore
+	// now 用于测试中替换时钟
+	now func() time.Time
+}
+
+// newConfigMapJournal 创建持久化到 namespace/name ConfigMap 的 journal
+func newConfigMapJournal(client kubernetes.Interface, namespace, name string) *challengeJournal {
+	return &challengeJournal{store: &configMapStore{client: client, namespace: namespace, name: name}}
+}
+
+func (j *challengeJournal) currentTime() time.Time {
+	if j.now != nil {
+		return j.now()
+	}
+	return time.Now()
+}
+
+// put 记录 key 对应的 TXT 记录，持久化失败只记录日志
+func (j *challengeJournal) put(ctx context.Context, key string, entry journalEntry) {
+	if j == nil || key == "" {
+		return
+	}
+	entry.CreatedAt = j.currentTime()
+
+	j.mu.Lock()
+	if j.entries == nil {
+		j.entries = map[string]journalEntry{}
+	}
+	j.entries[key] = entry
+	// 清理长期没有 CleanUp 的条目
+	for k, e := range j.entries {
+		if j.currentTime().Sub(e.CreatedAt) > journalRetention {
+			delete(j.entries, k)
+		}
+	}
+	j.updateMetrics()
+	j.mu.Unlock()
+
+	if err := j.persist(ctx, key, &entry); err != nil {
+		slog.Warn("Failed to persist challenge journal entry", "key", key, "error", err)
+	}
+}
+
+// get 返回 key 对应的 TXT 记录，内存中没有时从 ConfigMap 读取
+func (j *challengeJournal) get(ctx context.Context, key string) (journalEntry, bool) {
+	if j == nil || key == "" {
+		return journalEntry{}, false
+	}
+
+	j.mu.Lock()
+	entry, ok := j.entries[key]
+	j.mu.Unlock()
+	if ok || j.store == nil {
+		return entry, ok
+	}
+
+	data, ok, err := j.store.get(ctx, key)
+	if err != nil {
+		slog.Warn("Failed to read challenge journal", "configMap", j.store.name, "error", err)
+		return journalEntry{}, false
+	}
+	if !ok {
+		return journalEntry{}, false
+	}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		slog.Warn("Ignoring invalid challenge journal entry", "key", key, "error", err)
+		return journalEntry{}, false
+	}
+	return entry, true
+}
+
+// remove 删除 key 对应的条目
+func (j *challengeJournal) remove(ctx context.Context, key string) {
+	if j == nil || key == "" {
+		return
+	}
+
+	j.mu.Lock()
+	delete(j.entries, key)
+	j.updateMetrics()
+	j.mu.Unlock()
+
+	if err := j.persist(ctx, key, nil); err != nil {
+		slog.Warn("Failed to remove challenge journal entry", "key", key, "error", err)
+	}
+}
+
+// updateMetrics 更新 activeChallengeRecords，调用时需持有 j.mu。
+// 只统计本副本内存中的条目，其他副本创建的记录由其自身统计
+func (j *challengeJournal) updateMetrics() {
+	records := 0
+	for _, e := range j.entries {
+		records += len(e.RecordIDs)
+	}
+	activeChallengeRecords.Set(float64(records))
+}
+
+// persist 将 key 的条目写入 ConfigMap，entry 为 nil 时删除
+func (j *challengeJournal) persist(ctx context.Context, key string, entry *journalEntry) error {
+	if j.store == nil {
+		return nil
+	}
+
+	var data string
+	if entry != nil {
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = string(raw)
+	}
+
+	return j.store.update(ctx, func(stored map[string]string) bool {
+		if entry == nil {
+			if _, ok := stored[key]; !ok {
+				return false
+			}
+			delete(stored, key)
+			return true
+		}
+		stored[key] = data
+		j.pruneData(stored)
+		return true
+	})
+}
+
+// pruneData 删除 ConfigMap 中超过 journalRetention 或无法解析的条目
+func (j *challengeJournal) pruneData(data map[string]string) {
+	for k, raw := range data {
+		var e journalEntry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil || j.currentTime().Sub(e.CreatedAt) > journalRetention {
+			delete(data, k)
+		}
+	}
+}
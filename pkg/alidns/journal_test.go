@@ -0,0 +1,238 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestChallengeJournal_Memory(t *testing.T) {
+	ctx := context.Background()
+	journal := &challengeJournal{}
+
+	journal.put(ctx, "uid-1", journalEntry{Domain: "example.com", RR: "_acme-challenge", Value: "v", RecordIDs: []string{"record-1"}})
+	entry, ok := journal.get(ctx, "uid-1")
+	require.True(t, ok)
+	assert.Equal(t, []string{"record-1"}, entry.RecordIDs)
+	assert.False(t, entry.CreatedAt.IsZero())
+
+	journal.remove(ctx, "uid-1")
+	_, ok = journal.get(ctx, "uid-1")
+	assert.False(t, ok)
+
+	// 空 key 不记录
+	journal.put(ctx, "", journalEntry{RecordIDs: []string{"record-2"}})
+	assert.Empty(t, journal.entries)
+
+	// nil journal 为空操作
+	var disabled *challengeJournal
+	disabled.put(ctx, "uid-1", journalEntry{})
+	_, ok = disabled.get(ctx, "uid-1")
+	assert.False(t, ok)
+	disabled.remove(ctx, "uid-1")
+}
+
+func TestChallengeJournal_Retention(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	journal := &challengeJournal{now: func() time.Time { return now }}
+
+	journal.put(ctx, "old", journalEntry{RecordIDs: []string{"record-1"}})
+	now = now.Add(journalRetention + time.Hour)
+	journal.put(ctx, "new", journalEntry{RecordIDs: []string{"record-2"}})
+
+	_, ok := journal.get(ctx, "old")
+	assert.False(t, ok)
+	_, ok = journal.get(ctx, "new")
+	assert.True(t, ok)
+}
+
+func TestChallengeJournal_ConfigMap(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	journal := newConfigMapJournal(client, "cert-manager", "alidns-journal")
+
+	journal.put(ctx, "uid-1", journalEntry{Domain: "example.com", RR: "_acme-challenge", Value: "v", RecordIDs: []string{"record-1"}})
+	journal.put(ctx, "uid-2", journalEntry{Domain: "example.com", RR: "_acme-challenge", Value: "w", RecordIDs: []string{"record-2"}})
+
+	cm, err := client.CoreV1().ConfigMaps("cert-manager").Get(ctx, "alidns-journal", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, cm.Data, "uid-1")
+	var stored journalEntry
+	require.NoError(t, json.Unmarshal([]byte(cm.Data["uid-1"]), &stored))
+	assert.Equal(t, []string{"record-1"}, stored.RecordIDs)
+
+	// 其他副本（或重启后）从 ConfigMap 读取
+	other := newConfigMapJournal(client, "cert-manager", "alidns-journal")
+	entry, ok := other.get(ctx, "uid-2")
+	require.True(t, ok)
+	assert.Equal(t, []string{"record-2"}, entry.RecordIDs)
+
+	other.remove(ctx, "uid-2")
+	cm, err = client.CoreV1().ConfigMaps("cert-manager").Get(ctx, "alidns-journal", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, cm.Data, "uid-2")
+	assert.Contains(t, cm.Data, "uid-1")
+}
+
+func TestSolver_CleanUp_UsesJournal(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{
+		UID:                     "challenge-uid",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key",
+		AllowAmbientCredentials: true,
+	}
+
+	tests := []struct {
+		name           string
+		present        bool
+		deleteErr      error
+		expectByID     []string
+		expectScan     int
+		expectResolves int
+	}{
+		{
+			name:           "delete by record ID",
+			present:        true,
+			expectByID:     []string{"record-1"},
+			expectResolves: 1,
+		},
+		{
+			name:           "no journal entry falls back to scan",
+			present:        false,
+			expectScan:     1,
+			expectResolves: 1,
+		},
+		{
+			name:           "delete by ID fails falls back to scan",
+			present:        true,
+			deleteErr:      errors.New("record not found"),
+			expectByID:     []string{"record-1"},
+			expectScan:     1,
+			expectResolves: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var byID []string
+			scans, resolves := 0, 0
+			provider := &MockDNSProvider{
+				ResolveDomainFunc: func(fqdn string) (string, string, error) {
+					resolves++
+					return "example.com", "_acme-challenge", nil
+				},
+				AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+					return "record-1", nil
+				},
+				DeleteRecordFunc: func(recordId string) error {
+					byID = append(byID, recordId)
+					return tt.deleteErr
+				},
+				DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+					scans++
+					return nil
+				},
+			}
+			solver := NewSolver(provider)
+
+			if tt.present {
+				require.NoError(t, solver.Present(ch))
+			}
+			require.NoError(t, solver.CleanUp(ch))
+
+			assert.Equal(t, tt.expectByID, byID)
+			assert.Equal(t, tt.expectScan, scans)
+			assert.Equal(t, tt.expectResolves, resolves)
+			_, ok := solver.journal.get(context.Background(), journalKey(ch))
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestJournalKey(t *testing.T) {
+	base := &v1alpha1.ChallengeRequest{DNSName: "example.com", ResolvedFQDN: "_acme-challenge.example.com.", Key: "k1"}
+
+	tests := []struct {
+		name   string
+		ch     *v1alpha1.ChallengeRequest
+		expect bool
+	}{
+		{name: "uid is ignored", ch: &v1alpha1.ChallengeRequest{UID: "other", DNSName: "example.com", ResolvedFQDN: "_acme-challenge.example.com.", Key: "k1"}, expect: true},
+		{name: "dns name is case insensitive", ch: &v1alpha1.ChallengeRequest{DNSName: "Example.COM", Key: "k1"}, expect: true},
+		{name: "followed cname keeps dns name", ch: &v1alpha1.ChallengeRequest{DNSName: "example.com", ResolvedFQDN: "_acme-challenge.example.net.", Key: "k1"}, expect: true},
+		{name: "different key", ch: &v1alpha1.ChallengeRequest{DNSName: "example.com", Key: "k2"}, expect: false},
+		{name: "different dns name", ch: &v1alpha1.ChallengeRequest{DNSName: "www.example.com", Key: "k1"}, expect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, journalKey(base) == journalKey(tt.ch))
+		})
+	}
+
+	// 没有 DNSName 时使用 ResolvedFQDN
+	assert.Equal(t, challengeKey("_acme-challenge.example.com", "k1"),
+		journalKey(&v1alpha1.ChallengeRequest{ResolvedFQDN: "_acme-challenge.example.com.", Key: "k1"}))
+}
+
+func TestSolver_CleanUp_JournalIgnoresUID(t *testing.T) {
+	tests := []struct {
+		name       string
+		presentUID string
+		cleanupUID string
+	}{
+		{name: "empty uid", presentUID: "", cleanupUID: ""},
+		{name: "different uid", presentUID: "present-uid", cleanupUID: "cleanup-uid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var byID []string
+			scans := 0
+			solver := NewSolver(&MockDNSProvider{
+				AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+					return "record-1", nil
+				},
+				DeleteRecordFunc: func(recordId string) error {
+					byID = append(byID, recordId)
+					return nil
+				},
+				DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+					scans++
+					return nil
+				},
+			})
+			ch := func(uid string) *v1alpha1.ChallengeRequest {
+				return &v1alpha1.ChallengeRequest{
+					UID:                     types.UID(uid),
+					DNSName:                 "example.com",
+					ResolvedFQDN:            "_acme-challenge.example.com.",
+					ResolvedZone:            "example.com.",
+					Key:                     "test-key",
+					AllowAmbientCredentials: true,
+				}
+			}
+
+			require.NoError(t, solver.Present(ch(tt.presentUID)))
+			assert.Equal(t, float64(1), testutil.ToFloat64(activeChallengeRecords))
+
+			require.NoError(t, solver.CleanUp(ch(tt.cleanupUID)))
+			assert.Equal(t, []string{"record-1"}, byID)
+			assert.Zero(t, scans)
+			assert.Empty(t, solver.journal.entries)
+			assert.Equal(t, float64(0), testutil.ToFloat64(activeChallengeRecords))
+		})
+	}
+}
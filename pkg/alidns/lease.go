@@ -0,0 +1,209 @@
+package alidns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// leaseDuration 是 Lease 的有效期，持有者退出后其他副本最多等待这么久即可接管
+	leaseDuration = 15 * time.Second
+	// leaseRetryInterval 是 Lease 被其他副本持有时的重试间隔
+	leaseRetryInterval = 250 * time.Millisecond
+	// leaseReleaseTimeout 是释放 Lease 的超时时间
+	leaseReleaseTimeout = 5 * time.Second
+	// leaseNamePrefix 是 webhook 创建的 Lease 名称前缀
+	leaseNamePrefix = "alidns-webhook-"
+)
+
+// leaseLocker 使用 coordination.k8s.io/v1 Lease 在多个 webhook 副本之间
+// 串行化同一记录名（domain、rr）上的修改。
+// Lease API 不可用（未提供或没有权限）时退化为只使用进程内的锁
+type leaseLocker struct {
+	client    kubernetes.Interface
+	namespace string
+	// identity 是本副本在 Lease 中的持有者标识
+	identity string
+	// unavailable 在 Lease API 不可用后置为 true
+	unavailable atomic.Bool
+	// now 用于测试中替换时钟
+	now func() time.Time
+}
+
+// newLeaseLocker 创建 leaseLocker，identity 通常为 Pod 名称
+func newLeaseLocker(client kubernetes.Interface, namespace, identity string) *leaseLocker {
+	return &leaseLocker{
+		client:    client,
+		namespace: namespace,
+		identity:  identity,
+		now:       time.Now,
+	}
+}
+
+// leaseName 返回 (domain, rr) 对应的 Lease 名称
+func leaseName(domain, rr string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(domain) + "/" + strings.ToLower(rr)))
+	return leaseNamePrefix + hex.EncodeToString(sum[:])[:20]
+}
+
+// lock 获取 (domain, rr) 对应的 Lease，返回释放函数。
+// l 为 nil 或 Lease API 不可用时直接返回空操作
+func (l *leaseLocker) lock(ctx context.Context, domain, rr string) (func(), error) {
+	noop := func() {}
+	if l == nil || l.unavailable.Load() {
+		return noop, nil
+	}
+
+	name := leaseName(domain, rr)
+	for {
+		lease, err := l.tryAcquire(ctx, name)
+		if err != nil {
+			if isLeaseAPIUnavailable(err) {
+				if !l.unavailable.Swap(true) {
+					slog.Warn("Lease API unavailable, falling back to in-process locking",
+						"namespace", l.namespace,
+						"error", err,
+					)
+				}
+				return noop, nil
+			}
+			return nil, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+		}
+		if lease != nil {
+			return l.hold(lease), nil
+		}
+
+		// 其他副本持有，等待后重试
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for lease %s: %w", name, ctx.Err())
+		case <-time.After(leaseRetryInterval):
+		}
+	}
+}
+
+// tryAcquire 尝试获取 Lease，被其他副本持有时返回 nil, nil
+func (l *leaseLocker) tryAcquire(ctx context.Context, name string) (*coordinationv1.Lease, error) {
+	leases := l.client.CoordinationV1().Leases(l.namespace)
+	now := metav1.NewMicroTime(l.now())
+	durationSeconds := int32(leaseDuration / time.Second)
+
+	existing, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease, err := leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: l.namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return nil, nil
+		}
+		return lease, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.expired(existing) {
+		return nil, nil
+	}
+
+	// 上一个持有者已释放或过期，接管 Lease
+	lease := existing.DeepCopy()
+	lease.Spec.HolderIdentity = &l.identity
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	updated, err := leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+		// 被其他副本抢先接管或删除，稍后重试
+		return nil, nil
+	}
+	return updated, err
+}
+
+// expired 判断 Lease 是否没有有效的持有者
+func (l *leaseLocker) expired(lease *coordinationv1.Lease) bool {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	return !l.now().Before(expiry)
+}
+
+// hold 在持有期间定期续约，返回的函数停止续约并删除 Lease
+func (l *leaseLocker) hold(lease *coordinationv1.Lease) func() {
+	leases := l.client.CoordinationV1().Leases(l.namespace)
+	current := lease
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(leaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			renewed := current.DeepCopy()
+			now := metav1.NewMicroTime(l.now())
+			renewed.Spec.RenewTime = &now
+			ctx, cancel := context.WithTimeout(context.Background(), leaseReleaseTimeout)
+			updated, err := leases.Update(ctx, renewed, metav1.UpdateOptions{})
+			cancel()
+			if err != nil {
+				slog.Warn("Failed to renew lease", "lease", current.Name, "error", err)
+			} else {
+				current = updated
+			}
+		}
+	}()
+
+	return func() {
+		// 等待续约协程退出后 current 不再变化
+		close(stop)
+		<-done
+
+		ctx, cancel := context.WithTimeout(context.Background(), leaseReleaseTimeout)
+		defer cancel()
+		// 只删除自己持有的版本，避免删掉已被其他副本接管的 Lease
+		err := leases.Delete(ctx, current.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{
+				UID:             &current.UID,
+				ResourceVersion: &current.ResourceVersion,
+			},
+		})
+		if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+			slog.Warn("Failed to release lease", "lease", current.Name, "error", err)
+		}
+	}
+}
+
+// isLeaseAPIUnavailable 判断错误是否表示集群不提供 Lease API 或没有权限
+func isLeaseAPIUnavailable(err error) bool {
+	if apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err) {
+		return true
+	}
+	// 集群不提供 Lease API 时 Create 返回 NotFound（Get 和 Update 的 NotFound 已单独处理）
+	return apierrors.IsNotFound(err)
+}
@@ -0,0 +1,106 @@
+package alidns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestLeaseName(t *testing.T) {
+	name := leaseName("example.com", "_acme-challenge")
+	assert.Equal(t, name, leaseName("Example.COM", "_ACME-challenge"))
+	assert.NotEqual(t, name, leaseName("example.com", "_acme-challenge.www"))
+	assert.Len(t, name, len(leaseNamePrefix)+20)
+}
+
+func TestLeaseLocker_LockAndRelease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	locker := newLeaseLocker(client, "cert-manager", "pod-a")
+	name := leaseName("example.com", "_acme-challenge")
+
+	release, err := locker.lock(context.Background(), "example.com", "_acme-challenge")
+	require.NoError(t, err)
+
+	lease, err := client.CoordinationV1().Leases("cert-manager").Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "pod-a", *lease.Spec.HolderIdentity)
+
+	// 其他副本在释放前拿不到 Lease
+	other := newLeaseLocker(client, "cert-manager", "pod-b")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = other.lock(ctx, "example.com", "_acme-challenge")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 释放后删除 Lease，其他副本可以获取
+	release()
+	_, err = client.CoordinationV1().Leases("cert-manager").Get(context.Background(), name, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	releaseOther, err := other.lock(context.Background(), "example.com", "_acme-challenge")
+	require.NoError(t, err)
+	releaseOther()
+}
+
+func TestLeaseLocker_TakesOverExpiredLease(t *testing.T) {
+	name := leaseName("example.com", "_acme-challenge")
+	holder := "crashed-pod"
+	duration := int32(15)
+	renewTime := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	client := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "cert-manager"},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renewTime,
+		},
+	})
+	locker := newLeaseLocker(client, "cert-manager", "pod-a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	release, err := locker.lock(ctx, "example.com", "_acme-challenge")
+	require.NoError(t, err)
+	defer release()
+
+	lease, err := client.CoordinationV1().Leases("cert-manager").Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "pod-a", *lease.Spec.HolderIdentity)
+}
+
+func TestLeaseLocker_Unavailable(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "", nil)
+	})
+	locker := newLeaseLocker(client, "cert-manager", "pod-a")
+
+	// 没有 Lease 权限时退化为进程内锁，不返回错误
+	release, err := locker.lock(context.Background(), "example.com", "_acme-challenge")
+	require.NoError(t, err)
+	release()
+	assert.True(t, locker.unavailable.Load())
+
+	// 之后不再访问 Lease API
+	calls := len(client.Actions())
+	_, err = locker.lock(context.Background(), "example.com", "_acme-challenge")
+	require.NoError(t, err)
+	assert.Len(t, client.Actions(), calls)
+}
+
+func TestLeaseLocker_Nil(t *testing.T) {
+	var locker *leaseLocker
+	release, err := locker.lock(context.Background(), "example.com", "_acme-challenge")
+	require.NoError(t, err)
+	release()
+}
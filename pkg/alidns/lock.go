@@ -0,0 +1,62 @@
+package alidns
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// keyedMutex 为每个 key 提供一把互斥锁，不同 key 之间互不阻塞。
+// 零值可直接使用，没有等待者的 key 会在释放后移除
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	// ch 容量为 1，写入即加锁
+	ch chan struct{}
+	// refs 是持有和等待这把锁的数量
+	refs int
+}
+
+// lock 获取 key 对应的锁，ctx 取消时放弃等待并返回 ctx.Err()
+func (m *keyedMutex) lock(ctx context.Context, key string) (func(), error) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyedLock{}
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			m.release(key, l)
+		}, nil
+	case <-ctx.Done():
+		m.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+// release 减少引用计数，没有引用时移除 key
+func (m *keyedMutex) release(key string, l *keyedLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// recordLockKey 返回同一条 TXT 记录（domain、rr、value）使用的锁 key
+func recordLockKey(domain, rr, value string) string {
+	return strings.Join([]string{strings.ToLower(domain), strings.ToLower(rr), value}, "\x00")
+}
@@ -0,0 +1,119 @@
+package alidns
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var m keyedMutex
+	ctx := context.Background()
+
+	unlockA, err := m.lock(ctx, "a")
+	require.NoError(t, err)
+
+	// 不同 key 互不阻塞
+	unlockB, err := m.lock(ctx, "b")
+	require.NoError(t, err)
+	unlockB()
+
+	// 相同 key 在释放前无法获取
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = m.lock(waitCtx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	unlockA()
+	unlockA2, err := m.lock(ctx, "a")
+	require.NoError(t, err)
+	unlockA2()
+
+	// 释放后不再保留 key
+	assert.Empty(t, m.locks)
+}
+
+func TestKeyedMutex_Serializes(t *testing.T) {
+	var m keyedMutex
+	var mu sync.Mutex
+	var current, peak int
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := m.lock(context.Background(), "key")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer unlock()
+			mu.Lock()
+			current++
+			peak = max(peak, current)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			current--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, peak)
+	assert.Empty(t, m.locks)
+}
+
+func TestRecordLockKey(t *testing.T) {
+	assert.Equal(t, recordLockKey("Example.com", "_ACME-challenge", "v"), recordLockKey("example.com", "_acme-challenge", "v"))
+	assert.NotEqual(t, recordLockKey("example.com", "_acme-challenge", "v1"), recordLockKey("example.com", "_acme-challenge", "v2"))
+}
+
+func TestSolver_Present_ConcurrentSameChallenge(t *testing.T) {
+	// 同一 challenge 的并发 Present 不会同时执行查询和添加
+	var mu sync.Mutex
+	var inFlight, peak int
+	added := map[string]bool{}
+	provider := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			mu.Lock()
+			inFlight++
+			peak = max(peak, inFlight)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			inFlight--
+			added[value] = true
+			return "record-id", nil
+		},
+	}
+	solver := NewSolver(provider)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, solver.Present(&v1alpha1.ChallengeRequest{
+				ResolvedFQDN:            "_acme-challenge.example.com.",
+				ResolvedZone:            "example.com.",
+				Key:                     "test-key",
+				AllowAmbientCredentials: true,
+			}))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, peak)
+	assert.True(t, added["test-key"])
+}
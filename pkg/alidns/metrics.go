@@ -0,0 +1,196 @@
+package alidns
+
+import (
+	"sync"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// metricsNamespace 是 webhook 所有指标的前缀
+const metricsNamespace = "alidns_webhook"
+
+var (
+	// cleanupQueuePending 是等待重试删除的记录数
+	cleanupQueuePending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cleanup_queue_pending",
+		Help:      "Number of failed TXT record deletions waiting to be retried.",
+	})
+	// cleanupQueueRetries 按结果统计后台重试删除的次数
+	cleanupQueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cleanup_queue_retries_total",
+		Help:      "Background retries of failed TXT record deletions by result (success, failure, dropped).",
+	}, []string{"result"})
+
+	// solverOperations 按操作、zone 和结果统计 Present 和 CleanUp 的调用次数
+	solverOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "solver_operations_total",
+		Help:      "Present and CleanUp calls by operation, zone and result (success, failure).",
+	}, []string{"operation", "zone", "result"})
+	// solverOperationDuration 是 Present 和 CleanUp 的耗时，Present 包括等待记录生效的时间
+	solverOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "solver_operation_duration_seconds",
+		Help:      "Duration of Present and CleanUp calls by operation, zone and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"operation", "zone", "result"})
+	// apiRequests 按 API 和错误码统计 AliDNS 和 PrivateZone 的请求次数，每次重试单独计数
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_requests_total",
+		Help:      "AliDNS and PrivateZone API requests by action and error code (OK on success).",
+	}, []string{"action", "code"})
+	// apiRequestDuration 是单次 API 请求的耗时，不包括在限流器中排队的时间
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of AliDNS and PrivateZone API requests by action and error code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"action", "code"})
+	// apiRetries 按 API 统计 callWithRetry 的重试次数
+	apiRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_retries_total",
+		Help:      "Retries of AliDNS and PrivateZone API calls after throttling, 5xx or network errors.",
+	}, []string{"action"})
+	// rateLimiterWait 是 API 请求在限流器中等待令牌和并发名额的时间
+	rateLimiterWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rate_limiter_wait_seconds",
+		Help:      "Time API requests waited for the webhook's rate limiter by action.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 9),
+	}, []string{"action"})
+	// activeChallengeRecords 是 journal 中尚未 CleanUp 的 TXT 记录数
+	activeChallengeRecords = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "active_challenge_records",
+		Help:      "Number of challenge TXT records this replica created and has not cleaned up yet.",
+	})
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics 将指标注册到 webhook API server 的 /metrics
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.Registerer().MustRegister(
+			cleanupQueuePending,
+			cleanupQueueRetries,
+			solverOperations,
+			solverOperationDuration,
+			apiRequests,
+			apiRequestDuration,
+			apiRetries,
+			rateLimiterWait,
+			activeChallengeRecords,
+		)
+	})
+}
+
+// operationResult 返回指标中的结果标签
+func operationResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// observeOperation 记录一次 Present 或 CleanUp 的结果和耗时
+func observeOperation(operation, zone string, start time.Time, err error) {
+	result := operationResult(err)
+	solverOperations.WithLabelValues(operation, zone, result).Inc()
+	solverOperationDuration.WithLabelValues(operation, zone, result).Observe(time.Since(start).Seconds())
+}
+
+// errorCodeLabel 返回 API 请求在指标中的错误码，成功时为 OK，非 SDK 错误（例如网络错误）为 Unknown
+func errorCodeLabel(err error) string {
+	if err == nil {
+		return "OK"
+	}
+	if sdkErr, ok := asSDKError(err); ok && sdkErr.code != "" {
+		return sdkErr.code
+	}
+	return "Unknown"
+}
+
+// observeAPICall 执行一次 API 请求并记录其结果和耗时
+func observeAPICall[T any](action string, call func() (T, error)) (T, error) {
+	start := time.Now()
+	response, err := call()
+	code := errorCodeLabel(err)
+	apiRequests.WithLabelValues(action, code).Inc()
+	apiRequestDuration.WithLabelValues(action, code).Observe(time.Since(start).Seconds())
+	return response, err
+}
+
+// instrumentedClient 为 AliDNSClient 的每次请求记录指标
+type instrumentedClient struct {
+	client AliDNSClient
+}
+
+func (c *instrumentedClient) AddDomainRecordWithOptions(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+	return observeAPICall("AddDomainRecord", func() (*alidns.AddDomainRecordResponse, error) {
+		return c.client.AddDomainRecordWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) DeleteDomainRecordWithOptions(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+	return observeAPICall("DeleteDomainRecord", func() (*alidns.DeleteDomainRecordResponse, error) {
+		return c.client.DeleteDomainRecordWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) DescribeDomainRecordsWithOptions(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error) {
+	return observeAPICall("DescribeDomainRecords", func() (*alidns.DescribeDomainRecordsResponse, error) {
+		return c.client.DescribeDomainRecordsWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) DescribeDomainsWithOptions(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+	return observeAPICall("DescribeDomains", func() (*alidns.DescribeDomainsResponse, error) {
+		return c.client.DescribeDomainsWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) DescribeDomainInfoWithOptions(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+	return observeAPICall("DescribeDomainInfo", func() (*alidns.DescribeDomainInfoResponse, error) {
+		return c.client.DescribeDomainInfoWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) DescribeSubDomainRecordsWithOptions(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+	return observeAPICall("DescribeSubDomainRecords", func() (*alidns.DescribeSubDomainRecordsResponse, error) {
+		return c.client.DescribeSubDomainRecordsWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) SetDomainRecordStatusWithOptions(request *alidns.SetDomainRecordStatusRequest, runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error) {
+	return observeAPICall("SetDomainRecordStatus", func() (*alidns.SetDomainRecordStatusResponse, error) {
+		return c.client.SetDomainRecordStatusWithOptions(request, runtime)
+	})
+}
+
+func (c *instrumentedClient) UpdateDomainRecordRemarkWithOptions(request *alidns.UpdateDomainRecordRemarkRequest, runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error) {
+	return observeAPICall("UpdateDomainRecordRemark", func() (*alidns.UpdateDomainRecordRemarkResponse, error) {
+		return c.client.UpdateDomainRecordRemarkWithOptions(request, runtime)
+	})
+}
+
+// instrumentedPrivateZoneClient 为 PrivateZoneClient 的每次请求记录指标
+type instrumentedPrivateZoneClient struct {
+	client PrivateZoneClient
+}
+
+func (c *instrumentedPrivateZoneClient) CallApi(params *openapiutil.Params, request *openapiutil.OpenApiRequest, runtime *util.RuntimeOptions) (map[string]interface{}, error) {
+	return observeAPICall(tea.StringValue(params.Action), func() (map[string]interface{}, error) {
+		return c.client.CallApi(params, request, runtime)
+	})
+}
@@ -0,0 +1,161 @@
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCodeLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		expect string
+	}{
+		{name: "success", err: nil, expect: "OK"},
+		{name: "sdk error", err: newSDKError("Forbidden.RAM", 403), expect: "Forbidden.RAM"},
+		{name: "openapi error", err: newOpenAPIError("Throttling.User", 400), expect: "Throttling.User"},
+		{name: "wrapped api error", err: fmt.Errorf("failed: %w", newAPIError("AddDomainRecord", newSDKError("QuotaExceeded.Record", 400))), expect: "QuotaExceeded.Record"},
+		{name: "network error", err: fmt.Errorf("connection reset"), expect: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, errorCodeLabel(tt.err))
+		})
+	}
+}
+
+func TestInstrumentedClient(t *testing.T) {
+	client := &instrumentedClient{client: &MockAliDNSClient{
+		DeleteDomainRecordFunc: func(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+			if tea.StringValue(request.RecordId) == "locked" {
+				return nil, newOpenAPIError("DomainRecordLocked", 400)
+			}
+			return &alidns.DeleteDomainRecordResponse{}, nil
+		},
+	}}
+	success := apiRequests.WithLabelValues("DeleteDomainRecord", "OK")
+	locked := apiRequests.WithLabelValues("DeleteDomainRecord", "DomainRecordLocked")
+	beforeSuccess, beforeLocked := testutil.ToFloat64(success), testutil.ToFloat64(locked)
+
+	_, err := client.DeleteDomainRecordWithOptions(&alidns.DeleteDomainRecordRequest{RecordId: tea.String("12345")}, nil)
+	require.NoError(t, err)
+	_, err = client.DeleteDomainRecordWithOptions(&alidns.DeleteDomainRecordRequest{RecordId: tea.String("locked")}, nil)
+	require.Error(t, err)
+
+	assert.Equal(t, beforeSuccess+1, testutil.ToFloat64(success))
+	assert.Equal(t, beforeLocked+1, testutil.ToFloat64(locked))
+	assert.Positive(t, testutil.CollectAndCount(apiRequestDuration, "alidns_webhook_api_request_duration_seconds"))
+
+	// PrivateZone 请求按 Action 记录
+	pvtz := &instrumentedPrivateZoneClient{client: newFakePrivateZone()}
+	counter := apiRequests.WithLabelValues("DescribeZones", "OK")
+	before := testutil.ToFloat64(counter)
+	_, err = pvtz.CallApi(&openapiutil.Params{Action: tea.String("DescribeZones")}, &openapiutil.OpenApiRequest{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestMetrics_Retries(t *testing.T) {
+	useFastRetries(t, time.Second)
+
+	counter := apiRetries.WithLabelValues("DescribeDomains")
+	before := testutil.ToFloat64(counter)
+	attempts := 0
+	_, err := callWithRetry(context.Background(), "DescribeDomains", func(runtime *util.RuntimeOptions) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", newSDKError("Throttling.User", 400)
+		}
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
+
+// histogramSampleCount 返回 histogram 的样本数
+func histogramSampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+	metric, ok := observer.(prometheus.Metric)
+	require.True(t, ok)
+	var m dto.Metric
+	require.NoError(t, metric.Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetrics_RateLimiterWait(t *testing.T) {
+	histogram := rateLimiterWait.WithLabelValues("MetricsTestAction")
+	before := histogramSampleCount(t, histogram)
+
+	limiter := newRateLimiter(rateLimitConfig{maxInFlight: 1})
+	release, err := limiter.acquire("MetricsTestAction", defaultAccount, nil)
+	require.NoError(t, err)
+	release()
+
+	assert.Equal(t, before+1, histogramSampleCount(t, histogram))
+}
+
+func TestMetrics_SolverOperations(t *testing.T) {
+	failing := false
+	solver := NewSolver(&MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			if failing {
+				return "", fmt.Errorf("mock api error")
+			}
+			return "12345", nil
+		},
+	})
+	ch := &v1alpha1.ChallengeRequest{
+		UID:                     "metrics-uid",
+		ResolvedFQDN:            "_acme-challenge.metrics.example.com.",
+		ResolvedZone:            "metrics.example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+	}
+	presentSuccess := solverOperations.WithLabelValues("present", "metrics.example.com", "success")
+	presentFailure := solverOperations.WithLabelValues("present", "metrics.example.com", "failure")
+	cleanupSuccess := solverOperations.WithLabelValues("cleanup", "metrics.example.com", "success")
+	beforePresent, beforeFailure, beforeCleanup := testutil.ToFloat64(presentSuccess), testutil.ToFloat64(presentFailure), testutil.ToFloat64(cleanupSuccess)
+
+	require.NoError(t, solver.Present(ch))
+	assert.Equal(t, beforePresent+1, testutil.ToFloat64(presentSuccess))
+	assert.Equal(t, float64(1), testutil.ToFloat64(activeChallengeRecords))
+
+	require.NoError(t, solver.CleanUp(ch))
+	assert.Equal(t, beforeCleanup+1, testutil.ToFloat64(cleanupSuccess))
+	assert.Equal(t, float64(0), testutil.ToFloat64(activeChallengeRecords))
+
+	failing = true
+	require.Error(t, solver.Present(ch))
+	assert.Equal(t, beforeFailure+1, testutil.ToFloat64(presentFailure))
+}
+
+func TestChallengeJournal_ActiveRecordsMetric(t *testing.T) {
+	journal := &challengeJournal{}
+	ctx := context.Background()
+
+	journal.put(ctx, "uid-1", journalEntry{RecordIDs: []string{"1", "2"}})
+	journal.put(ctx, "uid-2", journalEntry{RecordIDs: []string{"3"}})
+	assert.Equal(t, float64(3), testutil.ToFloat64(activeChallengeRecords))
+
+	// 重复 Present 不会重复计数
+	journal.put(ctx, "uid-2", journalEntry{RecordIDs: []string{"3"}})
+	assert.Equal(t, float64(3), testutil.ToFloat64(activeChallengeRecords))
+
+	journal.remove(ctx, "uid-1")
+	journal.remove(ctx, "uid-2")
+	assert.Equal(t, float64(0), testutil.ToFloat64(activeChallengeRecords))
+}
@@ -0,0 +1,185 @@
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+const (
+	// backendMirror 是 solver 配置中同时写入 AliDNS 和 PrivateZone 的 backend
+	backendMirror = "mirror"
+	// mirrorPolicyAll 要求所有 backend 都成功，是默认的失败策略
+	mirrorPolicyAll = "all"
+	// mirrorPolicyPublic 只要求公网 AliDNS 成功，PrivateZone 失败时只记录警告日志
+	mirrorPolicyPublic = "public"
+
+	// privateRecordIDPrefix 标识 mirrorProvider 返回的记录 ID 中属于 PrivateZone 的部分
+	privateRecordIDPrefix = "pvtz:"
+	// mirrorRecordIDSeparator 分隔 mirrorProvider 返回的记录 ID 中各 backend 的部分
+	mirrorRecordIDSeparator = ","
+)
+
+// mirrorProvider 将 TXT 记录同时写入公网 AliDNS 和同名的 PrivateZone。
+// 同一域名同时存在公网 zone 和 PrivateZone（split-horizon）时，
+// VPC 内的 Pod（包括 cert-manager 的自检）只能看到 PrivateZone 中的记录。
+// 返回的记录 ID 由两个 backend 的 ID 组成，例如 "12345,pvtz:678"
+type mirrorProvider struct {
+	public  DNSProvider
+	private DNSProvider
+	// requireAll 为 true 时 PrivateZone 的失败同样返回错误
+	requireAll bool
+}
+
+// newMirrorProvider 创建 mirrorProvider，policy 为空时使用 mirrorPolicyAll
+func newMirrorProvider(public, private DNSProvider, policy string) *mirrorProvider {
+	return &mirrorProvider{
+		public:     public,
+		private:    private,
+		requireAll: policy != mirrorPolicyPublic,
+	}
+}
+
+// privateFailed 处理 PrivateZone 的错误：requireAll 时返回 err，否则记录警告日志后忽略
+func (m *mirrorProvider) privateFailed(action string, err error, args ...any) error {
+	if m.requireAll {
+		return fmt.Errorf("PrivateZone mirror: %w", err)
+	}
+	slog.Warn("Ignoring PrivateZone mirror failure", append([]any{"action", action, "error", err}, args...)...)
+	return nil
+}
+
+// ResolveDomain 以公网 AliDNS 的 zone 为准，PrivateZone 中的 zone 在写入时单独解析
+func (m *mirrorProvider) ResolveDomain(ctx context.Context, fqdn string) (string, string, error) {
+	return m.public.ResolveDomain(ctx, fqdn)
+}
+
+// privateName 返回 PrivateZone 中对应 domain 和 rr 的 zone 和主机记录
+func (m *mirrorProvider) privateName(ctx context.Context, domain, rr string) (string, string, error) {
+	fqdn := domain
+	if rr != "@" && rr != "" {
+		fqdn = rr + "." + domain
+	}
+	return m.private.ResolveDomain(ctx, fqdn)
+}
+
+// AddTXTRecord 先写入公网 AliDNS，再写入 PrivateZone
+func (m *mirrorProvider) AddTXTRecord(ctx context.Context, domain, rr, value string, opts RecordOptions) (string, error) {
+	publicID, err := m.public.AddTXTRecord(ctx, domain, rr, value, opts)
+	if err != nil {
+		return "", err
+	}
+
+	privateDomain, privateRR, err := m.privateName(ctx, domain, rr)
+	if err != nil {
+		return publicID, m.privateFailed("AddTXTRecord", err, "domain", domain, "rr", rr)
+	}
+	// PrivateZone 不支持解析线路，各线路的记录在 PrivateZone 中只需要一条
+	privateOpts := opts
+	privateOpts.Line = ""
+	privateID, err := m.private.AddTXTRecord(ctx, privateDomain, privateRR, value, privateOpts)
+	if err != nil {
+		return publicID, m.privateFailed("AddTXTRecord", err, "domain", privateDomain, "rr", privateRR)
+	}
+	return publicID + mirrorRecordIDSeparator + privateRecordIDPrefix + privateID, nil
+}
+
+// splitRecordID 将 mirrorProvider 的记录 ID 拆分为公网 AliDNS 和 PrivateZone 的 ID
+func splitRecordID(recordId string) (publicID, privateID string) {
+	for _, part := range strings.Split(recordId, mirrorRecordIDSeparator) {
+		if id, ok := strings.CutPrefix(part, privateRecordIDPrefix); ok {
+			privateID = id
+		} else {
+			publicID = part
+		}
+	}
+	return publicID, privateID
+}
+
+// DeleteRecord 删除 ID 中包含的每个 backend 的记录
+func (m *mirrorProvider) DeleteRecord(ctx context.Context, recordId string) error {
+	publicID, privateID := splitRecordID(recordId)
+	if publicID != "" {
+		if err := m.public.DeleteRecord(ctx, publicID); err != nil {
+			return err
+		}
+	}
+	if privateID != "" {
+		if err := m.private.DeleteRecord(ctx, privateID); err != nil {
+			return m.privateFailed("DeleteRecord", err, "recordId", privateID)
+		}
+	}
+	return nil
+}
+
+// DeleteRecordsByKey 在两个 backend 中按 key 删除记录
+func (m *mirrorProvider) DeleteRecordsByKey(ctx context.Context, domain, rr, value, owner string) error {
+	if err := m.public.DeleteRecordsByKey(ctx, domain, rr, value, owner); err != nil {
+		return err
+	}
+
+	privateDomain, privateRR, err := m.privateName(ctx, domain, rr)
+	if err == nil {
+		err = m.private.DeleteRecordsByKey(ctx, privateDomain, privateRR, value, owner)
+	}
+	if err != nil {
+		return m.privateFailed("DeleteRecordsByKey", err, "domain", domain, "rr", rr)
+	}
+	return nil
+}
+
+// SetRecordRemark 为 ID 中包含的每个 backend 的记录设置备注
+func (m *mirrorProvider) SetRecordRemark(ctx context.Context, recordId, remark string) error {
+	publicID, privateID := splitRecordID(recordId)
+	if publicID != "" {
+		if err := m.public.SetRecordRemark(ctx, publicID, remark); err != nil {
+			return err
+		}
+	}
+	if privateID != "" {
+		if err := m.private.SetRecordRemark(ctx, privateID, remark); err != nil {
+			return m.privateFailed("SetRecordRemark", err, "recordId", privateID)
+		}
+	}
+	return nil
+}
+
+// DescribeRecords 返回两个 backend 中的记录，PrivateZone 记录的 ID 带有 privateRecordIDPrefix，
+// 回收孤儿记录时 DeleteRecord 据此删除对应 backend 的记录
+func (m *mirrorProvider) DescribeRecords(ctx context.Context, domain, rr string) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
+	public, ok := m.public.(recordScanner)
+	if !ok {
+		return nil, fmt.Errorf("public provider does not support scanning records")
+	}
+	records, err := public.DescribeRecords(ctx, domain, rr)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := m.private.(recordScanner)
+	if !ok {
+		return records, nil
+	}
+	privateRecords, err := private.DescribeRecords(ctx, domain, rr)
+	if err != nil {
+		return records, m.privateFailed("DescribeRecords", err, "domain", domain)
+	}
+	for _, record := range privateRecords {
+		record.RecordId = tea.String(privateRecordIDPrefix + tea.StringValue(record.RecordId))
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Nameservers 返回公网 AliDNS 的权威 DNS 服务器，PrivateZone 没有可查询的权威服务器
+func (m *mirrorProvider) Nameservers(ctx context.Context, domain string) ([]string, error) {
+	lister, ok := m.public.(nameserverLister)
+	if !ok {
+		return nil, fmt.Errorf("public provider does not support looking up nameservers")
+	}
+	return lister.Nameservers(ctx, domain)
+}
@@ -0,0 +1,148 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMirrorTestPrivateZone 返回包含已绑定 VPC 的 example.com PrivateZone 的 provider
+func newMirrorTestPrivateZone() (*fakePrivateZone, *privateZoneProvider) {
+	api := newFakePrivateZone()
+	api.addZone("zone-example", "example.com", "vpc-a")
+	return api, newPrivateZoneProvider(api, "vpc-a")
+}
+
+func TestSplitRecordID(t *testing.T) {
+	tests := []struct {
+		recordId      string
+		expectPublic  string
+		expectPrivate string
+	}{
+		{recordId: "12345,pvtz:678", expectPublic: "12345", expectPrivate: "678"},
+		{recordId: "12345", expectPublic: "12345"},
+		{recordId: "pvtz:678", expectPrivate: "678"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.recordId, func(t *testing.T) {
+			publicID, privateID := splitRecordID(tt.recordId)
+			assert.Equal(t, tt.expectPublic, publicID)
+			assert.Equal(t, tt.expectPrivate, privateID)
+		})
+	}
+}
+
+func TestMirrorProvider(t *testing.T) {
+	var deleted []string
+	public := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "12345", nil
+		},
+		DeleteRecordFunc: func(recordId string) error {
+			deleted = append(deleted, recordId)
+			return nil
+		},
+	}
+	api, private := newMirrorTestPrivateZone()
+	provider := newMirrorProvider(public, private, "")
+	ctx := context.Background()
+
+	recordID, err := provider.AddTXTRecord(ctx, "example.com", "_acme-challenge.www", "value-1", RecordOptions{Line: "telecom"})
+	require.NoError(t, err)
+	require.Len(t, api.records["zone-example"], 1)
+	privateRecord := api.records["zone-example"][0]
+	assert.Equal(t, "12345,pvtz:"+privateRecord.id(), recordID)
+	assert.Equal(t, "_acme-challenge.www", privateRecord.Rr)
+	assert.Equal(t, []RecordOptions{{Line: "telecom"}}, public.AddedOptions)
+
+	require.NoError(t, provider.SetRecordRemark(ctx, recordID, "marker"))
+	assert.Equal(t, "marker", api.records["zone-example"][0].Remark)
+
+	require.NoError(t, provider.DeleteRecord(ctx, recordID))
+	assert.Equal(t, []string{"12345"}, deleted)
+	assert.Empty(t, api.records["zone-example"])
+}
+
+func TestMirrorProvider_FailurePolicy(t *testing.T) {
+	failing := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "", errors.New("pvtz unavailable")
+		},
+		DeleteRecordFunc: func(recordId string) error {
+			return errors.New("pvtz unavailable")
+		},
+		DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+			return errors.New("pvtz unavailable")
+		},
+	}
+	public := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "12345", nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		policy      string
+		expectError bool
+	}{
+		{name: "default requires all backends", policy: "", expectError: true},
+		{name: "all", policy: mirrorPolicyAll, expectError: true},
+		{name: "public only", policy: mirrorPolicyPublic, expectError: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newMirrorProvider(public, failing, tt.policy)
+			ctx := context.Background()
+
+			recordID, addErr := provider.AddTXTRecord(ctx, "example.com", "_acme-challenge", "value-1", RecordOptions{})
+			deleteErr := provider.DeleteRecord(ctx, "12345,pvtz:678")
+			byKeyErr := provider.DeleteRecordsByKey(ctx, "example.com", "_acme-challenge", "value-1", "")
+			if tt.expectError {
+				assert.ErrorContains(t, addErr, "PrivateZone mirror: pvtz unavailable")
+				assert.ErrorContains(t, deleteErr, "PrivateZone mirror")
+				assert.ErrorContains(t, byKeyErr, "PrivateZone mirror")
+				return
+			}
+			require.NoError(t, addErr)
+			assert.Equal(t, "12345", recordID)
+			assert.NoError(t, deleteErr)
+			assert.NoError(t, byKeyErr)
+		})
+	}
+
+	// 公网 AliDNS 的失败总是返回错误
+	provider := newMirrorProvider(&MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "", errors.New("alidns unavailable")
+		},
+	}, failing, mirrorPolicyPublic)
+	_, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "value-1", RecordOptions{})
+	assert.ErrorContains(t, err, "alidns unavailable")
+}
+
+func TestSolver_SweepZone_Mirror(t *testing.T) {
+	marker := recordMarker{Owner: defaultOwnerID, Created: time.Now().Add(-48 * time.Hour)}.String()
+	client, deleted := newGCTestClient([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+		{RecordId: tea.String("orphaned"), RR: tea.String("_acme-challenge"), Value: tea.String("a"), Remark: tea.String(marker)},
+	})
+	api, private := newMirrorTestPrivateZone()
+	ctx := context.Background()
+	privateID, err := private.AddTXTRecord(ctx, "example.com", "_acme-challenge", "a", RecordOptions{})
+	require.NoError(t, err)
+	require.NoError(t, private.SetRecordRemark(ctx, privateID, marker))
+
+	solver := NewSolver(newMirrorProvider(newDNSProviderWithClient(client), private, ""))
+	cfg := gcConfig{zones: []string{"example.com"}, interval: time.Hour, maxAge: 24 * time.Hour}
+	require.NoError(t, solver.sweepZone(t.Context(), "example.com", cfg))
+	assert.Equal(t, []string{"orphaned"}, deleted())
+	assert.Empty(t, api.records["zone-example"])
+}
@@ -0,0 +1,99 @@
+package alidns
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// recordMarkerPrefix 标识由本 webhook 创建的记录的备注
+	recordMarkerPrefix = "cert-manager-alidns-webhook"
+	// defaultOwnerID 是未配置 ALIDNS_OWNER_ID 时使用的所有者标识
+	defaultOwnerID = "default"
+)
+
+// recordMarker 是写入记录备注（Remark）的所有权标记，格式为
+//
+//	cert-manager-alidns-webhook;owner=<owner>;name=<fqdn>;created=<RFC3339>
+//
+// 旧版本写入的 uid 字段会被忽略
+type recordMarker struct {
+	Owner   string
+	Name    string
+	Created time.Time
+}
+
+// String 返回写入备注的标记
+func (m recordMarker) String() string {
+	return fmt.Sprintf("%s;owner=%s;name=%s;created=%s",
+		recordMarkerPrefix, m.Owner, m.Name, m.Created.UTC().Format(time.RFC3339))
+}
+
+// parseRecordMarker 解析记录备注，不是本 webhook 写入的标记时返回 false
+func parseRecordMarker(remark string) (recordMarker, bool) {
+	fields := strings.Split(remark, ";")
+	if len(fields) == 0 || fields[0] != recordMarkerPrefix {
+		return recordMarker{}, false
+	}
+
+	var m recordMarker
+	for _, field := range fields[1:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "owner":
+			m.Owner = value
+		case "name":
+			m.Name = value
+		case "created":
+			m.Created, _ = time.Parse(time.RFC3339, value)
+		}
+	}
+	return m, m.Owner != ""
+}
+
+// ownershipConfig 是记录所有权相关的 webhook 配置
+type ownershipConfig struct {
+	// owner 标识本 webhook 实例（或集群），多个集群共用 zone 时需各不相同
+	owner string
+	// strict 为 true 时按 key 删除记录只删除备注中 owner 与本实例一致的记录，
+	// 并且无法写入备注时 Present 失败
+	strict bool
+}
+
+// ownershipConfigFromEnv 从环境变量读取所有权配置：
+// ALIDNS_OWNER_ID（默认 default）和 ALIDNS_STRICT_OWNERSHIP
+func ownershipConfigFromEnv() (ownershipConfig, error) {
+	cfg := ownershipConfig{
+		owner:  os.Getenv("ALIDNS_OWNER_ID"),
+		strict: os.Getenv("ALIDNS_STRICT_OWNERSHIP") == "true",
+	}
+	if cfg.owner == "" {
+		cfg.owner = defaultOwnerID
+	}
+	if strings.ContainsAny(cfg.owner, ";= ") {
+		return ownershipConfig{}, fmt.Errorf("invalid ALIDNS_OWNER_ID %q: must not contain ';', '=' or spaces", cfg.owner)
+	}
+	return cfg, nil
+}
+
+// deleteOwner 返回按 key 删除记录时要求的所有者，不限制时为空
+func (c ownershipConfig) deleteOwner() string {
+	if !c.strict {
+		return ""
+	}
+	return c.owner
+}
+
+// ownedBy 判断记录备注中的所有者是否为 owner，owner 为空时视为不限制
+func ownedBy(remark *string, owner string) bool {
+	if owner == "" {
+		return true
+	}
+	if remark == nil {
+		return false
+	}
+	m, ok := parseRecordMarker(*remark)
+	return ok && m.Owner == owner
+}
@@ -0,0 +1,160 @@
+package alidns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordMarker(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	marker := recordMarker{Owner: "cluster-a", Name: "_acme-challenge.example.com", Created: created}
+
+	remark := marker.String()
+	assert.Equal(t, "cert-manager-alidns-webhook;owner=cluster-a;name=_acme-challenge.example.com;created=2025-01-02T03:04:05Z", remark)
+
+	parsed, ok := parseRecordMarker(remark)
+	require.True(t, ok)
+	assert.Equal(t, marker, parsed)
+
+	// 旧版本写入的 uid 字段被忽略
+	parsed, ok = parseRecordMarker("cert-manager-alidns-webhook;owner=cluster-a;uid=challenge-uid;name=_acme-challenge.example.com;created=2025-01-02T03:04:05Z")
+	require.True(t, ok)
+	assert.Equal(t, marker, parsed)
+
+	for _, remark := range []string{"", "created by hand", "cert-manager-alidns-webhook", "other;owner=cluster-a"} {
+		_, ok := parseRecordMarker(remark)
+		assert.False(t, ok, remark)
+	}
+}
+
+func TestOwnershipConfigFromEnv(t *testing.T) {
+	mustUnsetEnv(t, "ALIDNS_OWNER_ID")
+	mustUnsetEnv(t, "ALIDNS_STRICT_OWNERSHIP")
+	cfg, err := ownershipConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, ownershipConfig{owner: defaultOwnerID}, cfg)
+	assert.Empty(t, cfg.deleteOwner())
+
+	mustSetEnv(t, "ALIDNS_OWNER_ID", "cluster-a")
+	mustSetEnv(t, "ALIDNS_STRICT_OWNERSHIP", "true")
+	cfg, err = ownershipConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "cluster-a", cfg.deleteOwner())
+
+	// owner 不能包含标记的分隔符
+	mustSetEnv(t, "ALIDNS_OWNER_ID", "cluster;a")
+	_, err = ownershipConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestDeleteRecordsByKey_Ownership(t *testing.T) {
+	owned := recordMarker{Owner: "cluster-a", Name: "_acme-challenge.example.com"}.String()
+	other := recordMarker{Owner: "cluster-b", Name: "_acme-challenge.example.com"}.String()
+	records := []*alidns.DescribeSubDomainRecordsResponseBodyDomainRecordsRecord{
+		{RecordId: tea.String("owned"), RR: tea.String("_acme-challenge"), Value: tea.String("v"), Remark: tea.String(owned)},
+		{RecordId: tea.String("other"), RR: tea.String("_acme-challenge"), Value: tea.String("v"), Remark: tea.String(other)},
+		{RecordId: tea.String("manual"), RR: tea.String("_acme-challenge"), Value: tea.String("v")},
+	}
+
+	tests := []struct {
+		name          string
+		owner         string
+		expectDeleted []string
+	}{
+		{name: "no owner deletes all matching records", owner: "", expectDeleted: []string{"owned", "other", "manual"}},
+		{name: "owner only deletes owned records", owner: "cluster-a", expectDeleted: []string{"owned"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			mockClient := &MockAliDNSClient{
+				DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+					return &alidns.DescribeSubDomainRecordsResponse{
+						Body: &alidns.DescribeSubDomainRecordsResponseBody{
+							TotalCount:    tea.Int64(int64(len(records))),
+							DomainRecords: &alidns.DescribeSubDomainRecordsResponseBodyDomainRecords{Record: records},
+						},
+					}, nil
+				},
+				DeleteDomainRecordFunc: func(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+					deleted = append(deleted, *request.RecordId)
+					return &alidns.DeleteDomainRecordResponse{}, nil
+				},
+			}
+
+			provider := newDNSProviderWithClient(mockClient)
+			require.NoError(t, provider.DeleteRecordsByKey(context.Background(), "example.com", "_acme-challenge", "v", tt.owner))
+			assert.Equal(t, tt.expectDeleted, deleted)
+		})
+	}
+}
+
+func TestSolver_Present_TagsRecord(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{
+		UID:                     "challenge-uid",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key",
+		AllowAmbientCredentials: true,
+	}
+
+	tests := []struct {
+		name        string
+		strict      bool
+		remarkErr   error
+		expectError bool
+	}{
+		{name: "tagged"},
+		{name: "tag failure is ignored", remarkErr: assert.AnError},
+		{name: "tag failure fails in strict mode", strict: true, remarkErr: assert.AnError, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remarks map[string]string
+			names, calls := []string{"example.com"}, 0
+			mockClient := &MockAliDNSClient{
+				DescribeDomainsFunc: newDescribeDomainsFunc(&names, &calls),
+				AddDomainRecordFunc: func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+					return &alidns.AddDomainRecordResponse{Body: &alidns.AddDomainRecordResponseBody{RecordId: tea.String("record-1")}}, nil
+				},
+				UpdateDomainRecordRemarkFunc: func(request *alidns.UpdateDomainRecordRemarkRequest, runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error) {
+					if tt.remarkErr != nil {
+						return nil, tt.remarkErr
+					}
+					remarks = map[string]string{*request.RecordId: *request.Remark}
+					return &alidns.UpdateDomainRecordRemarkResponse{}, nil
+				},
+			}
+			provider := newDNSProviderWithClient(mockClient)
+			solver := NewSolver(provider)
+			solver.ownership = ownershipConfig{owner: "cluster-a", strict: tt.strict}
+
+			err := solver.Present(ch)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.remarkErr != nil {
+				return
+			}
+
+			require.Contains(t, remarks, "record-1")
+			marker, ok := parseRecordMarker(remarks["record-1"])
+			require.True(t, ok)
+			assert.Equal(t, "cluster-a", marker.Owner)
+			assert.Equal(t, "_acme-challenge.example.com", marker.Name)
+			assert.False(t, marker.Created.IsZero())
+		})
+	}
+}
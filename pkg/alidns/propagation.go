@@ -0,0 +1,157 @@
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/issuer/acme/dns/util"
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+const (
+	// defaultPropagationTimeout 是等待权威 DNS 服务器生效的默认最长时间
+	defaultPropagationTimeout = time.Minute
+	// defaultPropagationInterval 是两次查询权威 DNS 服务器之间的默认间隔
+	defaultPropagationInterval = 2 * time.Second
+)
+
+// propagationConfig 是 Present 返回前等待记录在权威 DNS 服务器上生效的配置
+type propagationConfig struct {
+	// enabled 为 true 时 Present 等待记录生效后再返回
+	enabled bool
+	// timeout 是最长等待时间，超时后只记录警告日志
+	timeout time.Duration
+	// interval 是两次查询之间的间隔
+	interval time.Duration
+}
+
+// propagationConfigFromEnv 从环境变量读取配置：
+// ALIDNS_PROPAGATION_WAIT、ALIDNS_PROPAGATION_TIMEOUT、ALIDNS_PROPAGATION_INTERVAL
+func propagationConfigFromEnv() (propagationConfig, error) {
+	cfg := propagationConfig{
+		enabled:  os.Getenv("ALIDNS_PROPAGATION_WAIT") == "true",
+		timeout:  defaultPropagationTimeout,
+		interval: defaultPropagationInterval,
+	}
+
+	var err error
+	if cfg.timeout, err = durationEnv("ALIDNS_PROPAGATION_TIMEOUT", cfg.timeout); err != nil {
+		return propagationConfig{}, err
+	}
+	if cfg.interval, err = durationEnv("ALIDNS_PROPAGATION_INTERVAL", cfg.interval); err != nil {
+		return propagationConfig{}, err
+	}
+	return cfg, nil
+}
+
+// nameserverLister 由能够查询域名权威 DNS 服务器的 DNSProvider 实现
+type nameserverLister interface {
+	Nameservers(ctx context.Context, domain string) ([]string, error)
+}
+
+// waitForPropagation 轮询 domain 的权威 DNS 服务器，直到所有服务器都返回 value 或超时。
+// 等待失败不影响 Present 的结果，cert-manager 之后仍会执行自检
+func (s *Solver) waitForPropagation(ctx context.Context, provider DNSProvider, domain, rr, value string) {
+	lister, ok := provider.(nameserverLister)
+	if !ok {
+		return
+	}
+	nameservers, err := lister.Nameservers(ctx, domain)
+	if err != nil {
+		slog.Warn("Failed to look up authoritative nameservers, skipping propagation wait", "domain", domain, "error", err)
+		return
+	}
+	if len(nameservers) == 0 {
+		slog.Warn("No authoritative nameservers assigned, skipping propagation wait", "domain", domain)
+		return
+	}
+
+	fqdn := util.ToFqdn(domain)
+	if rr != "@" && rr != "" {
+		fqdn = util.ToFqdn(rr + "." + domain)
+	}
+	// domain 和 rr 是 Unicode 形式，DNS 查询需要使用 Punycode
+	if ascii, err := idna.ToASCII(fqdn); err == nil {
+		fqdn = ascii
+	}
+	lookup := s.lookupTXT
+	if lookup == nil {
+		lookup = queryTXT
+	}
+
+	slog.Info("Waiting for TXT record on authoritative nameservers", "fqdn", fqdn, "nameservers", nameservers, "timeout", s.propagation.timeout)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, s.propagation.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(s.propagation.interval)
+	defer ticker.Stop()
+	for {
+		pending := pendingNameservers(ctx, lookup, fqdn, value, nameservers)
+		if len(pending) == 0 {
+			slog.Info("TXT record visible on authoritative nameservers", "fqdn", fqdn, "nameservers", nameservers, "elapsed", time.Since(start))
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Warn("Timed out waiting for TXT record on authoritative nameservers",
+				"fqdn", fqdn,
+				"pending", pending,
+				"elapsed", time.Since(start),
+			)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingNameservers 返回尚未返回 value 的权威 DNS 服务器，查询失败的服务器视为未生效
+func pendingNameservers(ctx context.Context, lookup func(ctx context.Context, fqdn, nameserver string) ([]string, error), fqdn, value string, nameservers []string) []string {
+	var pending []string
+	for _, ns := range nameservers {
+		values, err := lookup(ctx, fqdn, ns)
+		if err != nil {
+			slog.Debug("Failed to query authoritative nameserver", "nameserver", ns, "fqdn", fqdn, "error", err)
+			pending = append(pending, ns)
+			continue
+		}
+		found := false
+		for _, v := range values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			pending = append(pending, ns)
+		}
+	}
+	return pending
+}
+
+// queryTXT 直接向 nameserver 查询 fqdn 的 TXT 记录
+func queryTXT(ctx context.Context, fqdn, nameserver string) ([]string, error) {
+	msg, err := util.DNSQuery(ctx, fqdn, dns.TypeTXT, []string{net.JoinHostPort(nameserver, "53")}, false)
+	if err != nil {
+		return nil, err
+	}
+	// NXDOMAIN 表示记录尚未生效
+	if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("nameserver %s returned %s for %s", nameserver, dns.RcodeToString[msg.Rcode], fqdn)
+	}
+
+	var values []string
+	for _, answer := range msg.Answer {
+		if txt, ok := answer.(*dns.TXT); ok {
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+	}
+	return values, nil
+}
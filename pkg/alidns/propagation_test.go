@@ -0,0 +1,176 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagationConfigFromEnv(t *testing.T) {
+	mustUnsetEnv(t, "ALIDNS_PROPAGATION_WAIT")
+	mustUnsetEnv(t, "ALIDNS_PROPAGATION_TIMEOUT")
+	mustUnsetEnv(t, "ALIDNS_PROPAGATION_INTERVAL")
+
+	cfg, err := propagationConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, propagationConfig{timeout: defaultPropagationTimeout, interval: defaultPropagationInterval}, cfg)
+
+	mustSetEnv(t, "ALIDNS_PROPAGATION_WAIT", "true")
+	mustSetEnv(t, "ALIDNS_PROPAGATION_TIMEOUT", "30s")
+	mustSetEnv(t, "ALIDNS_PROPAGATION_INTERVAL", "500ms")
+	cfg, err = propagationConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, propagationConfig{enabled: true, timeout: 30 * time.Second, interval: 500 * time.Millisecond}, cfg)
+
+	mustSetEnv(t, "ALIDNS_PROPAGATION_TIMEOUT", "0s")
+	_, err = propagationConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestNameservers(t *testing.T) {
+	var requested string
+	provider := newDNSProviderWithClient(&MockAliDNSClient{
+		DescribeDomainInfoFunc: func(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+			requested = *request.DomainName
+			return &alidns.DescribeDomainInfoResponse{
+				Body: &alidns.DescribeDomainInfoResponseBody{
+					DnsServers: &alidns.DescribeDomainInfoResponseBodyDnsServers{
+						DnsServer: []*string{tea.String("ns1.alidns.com"), tea.String("ns2.alidns.com")},
+					},
+				},
+			}, nil
+		},
+	})
+
+	nameservers, err := provider.Nameservers(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", requested)
+	assert.Equal(t, []string{"ns1.alidns.com", "ns2.alidns.com"}, nameservers)
+}
+
+// nameserverProvider 是带有权威 DNS 服务器的 MockDNSProvider
+type nameserverProvider struct {
+	MockDNSProvider
+	nameservers []string
+	err         error
+}
+
+func (p *nameserverProvider) Nameservers(ctx context.Context, domain string) ([]string, error) {
+	return p.nameservers, p.err
+}
+
+func TestSolver_WaitForPropagation(t *testing.T) {
+	tests := []struct {
+		name          string
+		nameservers   []string
+		nameserverErr error
+		visibleAfter  int
+		expectPolls   int
+	}{
+		{name: "visible immediately", nameservers: []string{"ns1", "ns2"}, visibleAfter: 0, expectPolls: 1},
+		{name: "visible after polling", nameservers: []string{"ns1", "ns2"}, visibleAfter: 2, expectPolls: 3},
+		{name: "timeout", nameservers: []string{"ns1"}, visibleAfter: 1000, expectPolls: -1},
+		{name: "no nameservers", nameservers: nil, expectPolls: 0},
+		{name: "nameserver lookup fails", nameserverErr: errors.New("forbidden"), expectPolls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			queries := map[string]int{}
+			var fqdns []string
+			solver := NewSolver(nil)
+			solver.propagation = propagationConfig{enabled: true, timeout: 100 * time.Millisecond, interval: time.Millisecond}
+			solver.lookupTXT = func(ctx context.Context, fqdn, nameserver string) ([]string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				fqdns = append(fqdns, fqdn)
+				queries[nameserver]++
+				if queries[nameserver] > tt.visibleAfter {
+					return []string{"other", "test-key"}, nil
+				}
+				if nameserver == "ns2" {
+					return nil, errors.New("timeout")
+				}
+				return []string{"other"}, nil
+			}
+			provider := &nameserverProvider{nameservers: tt.nameservers, err: tt.nameserverErr}
+
+			solver.waitForPropagation(context.Background(), provider, "example.com", "_acme-challenge", "test-key")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.expectPolls < 0 {
+				assert.Greater(t, queries["ns1"], 1)
+				return
+			}
+			assert.Equal(t, tt.expectPolls, queries["ns1"])
+			for _, fqdn := range fqdns {
+				assert.Equal(t, "_acme-challenge.example.com.", fqdn)
+			}
+		})
+	}
+}
+
+func TestSolver_WaitForPropagation_IDN(t *testing.T) {
+	tests := []struct {
+		name       string
+		domain     string
+		rr         string
+		expectFQDN string
+	}{
+		{name: "unicode zone", domain: "例子.中国", rr: "_acme-challenge", expectFQDN: "_acme-challenge.xn--fsqu00a.xn--fiqs8s."},
+		{name: "unicode subdomain", domain: "example.com", rr: "_acme-challenge.测试", expectFQDN: "_acme-challenge.xn--0zwm56d.example.com."},
+		{name: "zone apex", domain: "例子.中国", rr: "@", expectFQDN: "xn--fsqu00a.xn--fiqs8s."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fqdns []string
+			solver := NewSolver(nil)
+			solver.propagation = propagationConfig{enabled: true, timeout: 100 * time.Millisecond, interval: time.Millisecond}
+			solver.lookupTXT = func(ctx context.Context, fqdn, nameserver string) ([]string, error) {
+				fqdns = append(fqdns, fqdn)
+				return []string{"test-key"}, nil
+			}
+			provider := &nameserverProvider{nameservers: []string{"ns1"}}
+
+			solver.waitForPropagation(context.Background(), provider, tt.domain, tt.rr, "test-key")
+			assert.Equal(t, []string{tt.expectFQDN}, fqdns)
+		})
+	}
+}
+
+func TestSolver_Present_WaitsForPropagation(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key",
+		AllowAmbientCredentials: true,
+	}
+	provider := &nameserverProvider{nameservers: []string{"ns1.alidns.com"}}
+	solver := NewSolver(provider)
+	solver.propagation = propagationConfig{enabled: true, timeout: time.Second, interval: time.Millisecond}
+
+	queried := 0
+	solver.lookupTXT = func(ctx context.Context, fqdn, nameserver string) ([]string, error) {
+		queried++
+		return []string{"test-key"}, nil
+	}
+	require.NoError(t, solver.Present(ch))
+	assert.Equal(t, 1, queried)
+
+	// 未启用时不查询
+	solver.propagation.enabled = false
+	require.NoError(t, solver.Present(ch))
+	assert.Equal(t, 1, queried)
+}
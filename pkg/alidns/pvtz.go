@@ -0,0 +1,480 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	credential "github.com/aliyun/credentials-go/credentials"
+)
+
+// Reference:
+// https://api.aliyun.com/product/pvtz
+const (
+	// backendAliDNS 是 solver 配置中选择公网权威解析的 backend，也是默认值
+	backendAliDNS = "alidns"
+	// backendPrivateZone 是 solver 配置中选择云解析 PrivateZone 的 backend
+	backendPrivateZone = "pvtz"
+	// privateZoneEndpoint 是 PrivateZone API 的 endpoint，所有 region 共用
+	privateZoneEndpoint = "pvtz.aliyuncs.com"
+	// privateZoneAPIVersion 是调用的 PrivateZone API 版本
+	privateZoneAPIVersion = "2018-01-01"
+	// privateZoneStatusEnable 是 SetZoneRecordStatus 启用记录时使用的状态
+	privateZoneStatusEnable = "ENABLE"
+)
+
+// PrivateZoneClient 定义云解析 PrivateZone 客户端接口。
+// PrivateZone 没有引入单独的 SDK，通过 OpenAPI 的通用调用访问其 RPC 接口，
+// *openapi.Client 满足该接口
+type PrivateZoneClient interface {
+	CallApi(params *openapiutil.Params, request *openapiutil.OpenApiRequest, runtime *util.RuntimeOptions) (map[string]interface{}, error)
+}
+
+// newPrivateZoneClient 使用指定凭据创建 PrivateZone 客户端，每次请求记录指标
+func newPrivateZoneClient(cred credential.Credential) (PrivateZoneClient, error) {
+	config := &openapi.Config{
+		Credential: cred,
+		Endpoint:   tea.String(privateZoneEndpoint),
+	}
+	client, err := openapi.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedPrivateZoneClient{client: client}, nil
+}
+
+// privateZone 是 DescribeZones 返回的 zone
+type privateZone struct {
+	ZoneId   string `json:"ZoneId"`
+	ZoneName string `json:"ZoneName"`
+}
+
+// privateZoneRecord 是 DescribeZoneRecords 返回的记录
+type privateZoneRecord struct {
+	RecordId        int64  `json:"RecordId"`
+	Rr              string `json:"Rr"`
+	Type            string `json:"Type"`
+	Value           string `json:"Value"`
+	Status          string `json:"Status"`
+	Remark          string `json:"Remark"`
+	CreateTimestamp int64  `json:"CreateTimestamp"`
+}
+
+// id 返回字符串形式的记录 ID，与 DNSProvider 的其他实现保持一致
+func (r privateZoneRecord) id() string {
+	return strconv.FormatInt(r.RecordId, 10)
+}
+
+// privateZoneProvider 是云解析 PrivateZone 的 DNSProvider 实现，
+// 用于通过 VPC 内网解析完成校验的内部 ACME CA（例如 step-ca）
+type privateZoneProvider struct {
+	client PrivateZoneClient
+	// vpcID 不为空时只使用绑定了该 VPC 的 zone，否则使用绑定了任意 VPC 的 zone
+	vpcID string
+
+	mu sync.Mutex
+	// zones 缓存 zone 名称到 ZoneId 的映射
+	zones map[string]cachedPrivateZone
+	// now 用于测试中替换时钟
+	now func() time.Time
+}
+
+// cachedPrivateZone 是 lookupZone 的结果，id 为空表示没有绑定了所需 VPC 的 zone
+type cachedPrivateZone struct {
+	id        string
+	unbound   bool
+	fetchedAt time.Time
+}
+
+// newPrivateZoneProvider 使用指定的 PrivateZoneClient 创建 privateZoneProvider
+func newPrivateZoneProvider(client PrivateZoneClient, vpcID string) *privateZoneProvider {
+	return &privateZoneProvider{
+		client: client,
+		vpcID:  vpcID,
+		zones:  map[string]cachedPrivateZone{},
+	}
+}
+
+func (p *privateZoneProvider) currentTime() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// ResolveDomain 返回覆盖 fqdn 且绑定了 VPC 的最长 zone 以及对应的主机记录（RR）
+func (p *privateZoneProvider) ResolveDomain(ctx context.Context, fqdn string) (string, string, error) {
+	name := normalizeZone(fqdn)
+
+	var unbound []string
+	labels := strings.Split(name, ".")
+	for i := range labels {
+		zone := strings.Join(labels[i:], ".")
+		id, notBound, err := p.lookupZone(ctx, zone)
+		if err != nil {
+			return "", "", err
+		}
+		if notBound {
+			unbound = append(unbound, zone)
+		}
+		if id == "" {
+			continue
+		}
+		if i == 0 {
+			return zone, "@", nil
+		}
+		return zone, strings.Join(labels[:i], "."), nil
+	}
+
+	if len(unbound) > 0 {
+		return "", "", fmt.Errorf("%w: PrivateZone %s covers %s but is not bound to %s; bind the zone to the VPC the ACME CA resolves from",
+			ErrZoneNotFound, strings.Join(unbound, ", "), name, p.vpcDescription())
+	}
+	return "", "", fmt.Errorf("%w: no PrivateZone in this Alibaba Cloud account covers %s; add the zone to PrivateZone or check the credentials in use", ErrZoneNotFound, name)
+}
+
+// vpcDescription 返回错误信息中描述 VPC 要求的文字
+func (p *privateZoneProvider) vpcDescription() string {
+	if p.vpcID != "" {
+		return "VPC " + p.vpcID
+	}
+	return "any VPC"
+}
+
+// zoneID 返回名为 name 且绑定了 VPC 的 zone 的 ID
+func (p *privateZoneProvider) zoneID(ctx context.Context, name string) (string, error) {
+	id, _, err := p.lookupZone(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("%w: no PrivateZone named %s is bound to %s", ErrZoneNotFound, name, p.vpcDescription())
+	}
+	return id, nil
+}
+
+// lookupZone 查找名为 name 且绑定了 VPC 的 zone 的 ID，结果缓存 zoneCacheTTL。
+// 没有这样的 zone 时返回空 ID，unbound 表示存在同名 zone 但都未绑定所需的 VPC。
+// 找不到 zone 的结果同样缓存，ResolveDomain 逐级查找父域名时不会每次都查询所有层级
+func (p *privateZoneProvider) lookupZone(ctx context.Context, name string) (id string, unbound bool, err error) {
+	name = normalizeZone(name)
+
+	p.mu.Lock()
+	cached, ok := p.zones[name]
+	p.mu.Unlock()
+	if ok && p.currentTime().Sub(cached.fetchedAt) < zoneCacheTTL {
+		return cached.id, cached.unbound, nil
+	}
+
+	zones, err := p.describeZones(ctx, name)
+	if err != nil {
+		return "", false, err
+	}
+
+	// 同名 zone 可以绑定到不同的 VPC，只使用绑定了所需 VPC 的 zone
+	var bound []string
+	for _, zone := range zones {
+		ok, err := p.boundToVPC(ctx, zone.ZoneId)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			bound = append(bound, zone.ZoneId)
+		}
+	}
+	if len(bound) > 1 {
+		return "", false, fmt.Errorf("multiple PrivateZones named %s are bound to VPCs (%s); set vpcId in the solver config",
+			name, strings.Join(bound, ", "))
+	}
+
+	result := cachedPrivateZone{unbound: len(bound) == 0 && len(zones) > 0, fetchedAt: p.currentTime()}
+	if len(bound) == 1 {
+		result.id = bound[0]
+	}
+	p.mu.Lock()
+	p.zones[name] = result
+	p.mu.Unlock()
+	return result.id, result.unbound, nil
+}
+
+// describeZones 查询名称与 name 完全相同的 zone
+func (p *privateZoneProvider) describeZones(ctx context.Context, name string) ([]privateZone, error) {
+	var zones []privateZone
+	for pageNumber := 1; ; pageNumber++ {
+		var response struct {
+			TotalItems int `json:"TotalItems"`
+			Zones      struct {
+				Zone []privateZone `json:"Zone"`
+			} `json:"Zones"`
+		}
+		err := p.call(ctx, "DescribeZones", map[string]interface{}{
+			"Keyword":    name,
+			"SearchMode": "EXACT",
+			"PageNumber": strconv.Itoa(pageNumber),
+			"PageSize":   strconv.Itoa(pageSizeRequest),
+		}, &response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe private zones: %w", err)
+		}
+
+		page := response.Zones.Zone
+		for _, zone := range page {
+			if normalizeZone(zone.ZoneName) == name {
+				zones = append(zones, zone)
+			}
+		}
+		if len(page) == 0 || pageNumber*pageSizeRequest >= response.TotalItems {
+			return zones, nil
+		}
+	}
+}
+
+// boundToVPC 判断 zone 是否绑定了 p.vpcID，未指定 VPC 时判断是否绑定了任意 VPC。
+// 未绑定 VPC 的 zone 不会被任何解析器查询到
+func (p *privateZoneProvider) boundToVPC(ctx context.Context, zoneID string) (bool, error) {
+	var response struct {
+		BindVpcs struct {
+			Vpc []struct {
+				VpcId string `json:"VpcId"`
+			} `json:"Vpc"`
+		} `json:"BindVpcs"`
+	}
+	if err := p.call(ctx, "DescribeZoneInfo", map[string]interface{}{"ZoneId": zoneID}, &response); err != nil {
+		return false, fmt.Errorf("failed to describe private zone %s: %w", zoneID, err)
+	}
+
+	for _, vpc := range response.BindVpcs.Vpc {
+		if p.vpcID == "" || vpc.VpcId == p.vpcID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddTXTRecord 添加 TXT 记录，记录已存在时返回现有记录的 ID，已暂停的记录会被重新启用
+func (p *privateZoneProvider) AddTXTRecord(ctx context.Context, domain, rr, value string, opts RecordOptions) (string, error) {
+	if opts.Line != "" && opts.Line != defaultLine {
+		return "", fmt.Errorf("resolution line %s is not supported by PrivateZone", opts.Line)
+	}
+	zoneID, err := p.zoneID(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+
+	// 查询现有记录
+	records, err := p.findRecords(ctx, zoneID, rr, "EXACT")
+	if err != nil {
+		return "", fmt.Errorf("failed to describe records: %w", err)
+	}
+	if record, ok := findPrivateZoneRecord(records, rr, value); ok {
+		return p.reuseRecord(ctx, domain, record)
+	}
+
+	// 添加新记录
+	query := map[string]interface{}{
+		"ZoneId": zoneID,
+		"Rr":     rr,
+		"Type":   recordType,
+		"Value":  value,
+	}
+	if opts.TTL > 0 {
+		query["Ttl"] = strconv.Itoa(min(opts.TTL, maxRecordTTL))
+	}
+	var response struct {
+		RecordId int64 `json:"RecordId"`
+	}
+	if err := p.call(ctx, "AddZoneRecord", query, &response); err != nil {
+		// 其他请求（例如另一个副本）可能已经添加了相同的记录，查询其 ID 后视为成功
+		if records, findErr := p.findRecords(ctx, zoneID, rr, "EXACT"); findErr == nil {
+			if record, ok := findPrivateZoneRecord(records, rr, value); ok {
+				return record.id(), nil
+			}
+		}
+		return "", fmt.Errorf("failed to add zone record: %w", err)
+	}
+
+	return strconv.FormatInt(response.RecordId, 10), nil
+}
+
+// reuseRecord 返回已存在记录的 ID，被暂停的记录不会被解析，先重新启用
+func (p *privateZoneProvider) reuseRecord(ctx context.Context, domain string, record privateZoneRecord) (string, error) {
+	if strings.EqualFold(record.Status, recordStatusDisable) {
+		slog.Info("Re-enabling disabled TXT record",
+			"domain", domain,
+			"rr", record.Rr,
+			"recordId", record.id(),
+		)
+		err := p.call(ctx, "SetZoneRecordStatus", map[string]interface{}{
+			"RecordId": record.id(),
+			"Status":   privateZoneStatusEnable,
+		}, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to enable zone record: %w", err)
+		}
+	}
+	return record.id(), nil
+}
+
+// findPrivateZoneRecord 返回 records 中主机记录为 rr 且值等于 value 的 TXT 记录
+func findPrivateZoneRecord(records []privateZoneRecord, rr, value string) (privateZoneRecord, bool) {
+	for _, record := range records {
+		if record.Rr == rr && record.Value == value {
+			return record, true
+		}
+	}
+	return privateZoneRecord{}, false
+}
+
+// DeleteRecord 删除记录
+func (p *privateZoneProvider) DeleteRecord(ctx context.Context, recordId string) error {
+	if err := p.call(ctx, "DeleteZoneRecord", map[string]interface{}{"RecordId": recordId}, nil); err != nil {
+		return fmt.Errorf("failed to delete zone record: %w", err)
+	}
+	return nil
+}
+
+// DeleteRecordsByKey 根据 domain、rr、value 删除记录，跳过不属于 owner 的记录
+func (p *privateZoneProvider) DeleteRecordsByKey(ctx context.Context, domain, rr, value, owner string) error {
+	zoneID, err := p.zoneID(ctx, domain)
+	if err != nil {
+		return err
+	}
+	records, err := p.findRecords(ctx, zoneID, rr, "EXACT")
+	if err != nil {
+		return fmt.Errorf("failed to describe records: %w", err)
+	}
+
+	for _, record := range records {
+		if record.Rr != rr || record.Value != value {
+			continue
+		}
+		if !ownedBy(tea.String(record.Remark), owner) {
+			slog.Warn("Refusing to delete TXT record not owned by this webhook",
+				"domain", domain,
+				"rr", rr,
+				"recordId", record.id(),
+				"remark", record.Remark,
+				"owner", owner,
+			)
+			continue
+		}
+		if err := p.DeleteRecord(ctx, record.id()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRecordRemark 设置记录的备注
+func (p *privateZoneProvider) SetRecordRemark(ctx context.Context, recordId, remark string) error {
+	err := p.call(ctx, "UpdateRecordRemark", map[string]interface{}{
+		"RecordId": recordId,
+		"Remark":   remark,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update zone record remark: %w", err)
+	}
+	return nil
+}
+
+// DescribeRecords 查询 domain 中主机记录包含 rr 的 TXT 记录，用于回收孤儿记录
+func (p *privateZoneProvider) DescribeRecords(ctx context.Context, domain, rr string) ([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, error) {
+	zoneID, err := p.zoneID(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	records, err := p.findRecords(ctx, zoneID, rr, "LIKE")
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe records: %w", err)
+	}
+
+	result := make([]*alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord, 0, len(records))
+	for _, record := range records {
+		result = append(result, &alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord{
+			DomainName:      tea.String(domain),
+			RecordId:        tea.String(record.id()),
+			RR:              tea.String(record.Rr),
+			Type:            tea.String(record.Type),
+			Value:           tea.String(record.Value),
+			Status:          tea.String(record.Status),
+			Remark:          tea.String(record.Remark),
+			CreateTimestamp: tea.Int64(record.CreateTimestamp),
+		})
+	}
+	return result, nil
+}
+
+// findRecords 分页查询 zone 中主机记录匹配 rr 的 TXT 记录，searchMode 为 EXACT 或 LIKE
+func (p *privateZoneProvider) findRecords(ctx context.Context, zoneID, rr, searchMode string) ([]privateZoneRecord, error) {
+	var records []privateZoneRecord
+	for pageNumber := 1; ; pageNumber++ {
+		var response struct {
+			TotalItems int `json:"TotalItems"`
+			Records    struct {
+				Record []privateZoneRecord `json:"Record"`
+			} `json:"Records"`
+		}
+		err := p.call(ctx, "DescribeZoneRecords", map[string]interface{}{
+			"ZoneId":     zoneID,
+			"Keyword":    rr,
+			"SearchMode": searchMode,
+			"PageNumber": strconv.Itoa(pageNumber),
+			"PageSize":   strconv.Itoa(pageSizeRequest),
+		}, &response)
+		if err != nil {
+			return nil, err
+		}
+
+		page := response.Records.Record
+		for _, record := range page {
+			if record.Type == recordType {
+				records = append(records, record)
+			}
+		}
+		if len(page) == 0 || pageNumber*pageSizeRequest >= response.TotalItems {
+			return records, nil
+		}
+	}
+}
+
+// call 调用 PrivateZone 的 RPC 接口 action，out 不为 nil 时将响应 body 解析到 out
+func (p *privateZoneProvider) call(ctx context.Context, action string, query map[string]interface{}, out interface{}) error {
+	params := &openapiutil.Params{
+		Action:      tea.String(action),
+		Version:     tea.String(privateZoneAPIVersion),
+		Protocol:    tea.String("HTTPS"),
+		Pathname:    tea.String("/"),
+		Method:      tea.String("POST"),
+		AuthType:    tea.String("AK"),
+		Style:       tea.String("RPC"),
+		ReqBodyType: tea.String("formData"),
+		BodyType:    tea.String("json"),
+	}
+	request := &openapiutil.OpenApiRequest{Query: openapiutil.Query(query)}
+
+	response, err := callWithRetry(ctx, action, func(runtime *util.RuntimeOptions) (map[string]interface{}, error) {
+		return p.client.CallApi(params, request, runtime)
+	})
+	if err != nil || out == nil {
+		return err
+	}
+
+	body, err := json.Marshal(response["body"])
+	if err == nil {
+		err = json.Unmarshal(body, out)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePrivateZone 是内存中的 PrivateZone API，实现 PrivateZoneClient
+type fakePrivateZone struct {
+	mu sync.Mutex
+	// zones 是 ZoneId 到 zone 名称的映射
+	zones map[string]string
+	// vpcs 是 ZoneId 到绑定的 VPC 的映射
+	vpcs    map[string][]string
+	records map[string][]privateZoneRecord
+	nextID  int64
+	calls   map[string]int
+}
+
+func newFakePrivateZone() *fakePrivateZone {
+	return &fakePrivateZone{
+		zones:   map[string]string{},
+		vpcs:    map[string][]string{},
+		records: map[string][]privateZoneRecord{},
+		nextID:  1000,
+		calls:   map[string]int{},
+	}
+}
+
+func (f *fakePrivateZone) addZone(id, name string, vpcs ...string) {
+	f.zones[id] = name
+	f.vpcs[id] = vpcs
+}
+
+func (f *fakePrivateZone) CallApi(params *openapiutil.Params, request *openapiutil.OpenApiRequest, runtime *util.RuntimeOptions) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	action := tea.StringValue(params.Action)
+	f.calls[action]++
+	query := func(key string) string { return tea.StringValue(request.Query[key]) }
+
+	var body interface{}
+	switch action {
+	case "DescribeZones":
+		var zones []privateZone
+		for id, name := range f.zones {
+			if name == query("Keyword") {
+				zones = append(zones, privateZone{ZoneId: id, ZoneName: name})
+			}
+		}
+		body = map[string]interface{}{"TotalItems": len(zones), "Zones": map[string]interface{}{"Zone": zones}}
+	case "DescribeZoneInfo":
+		var vpcs []map[string]string
+		for _, vpc := range f.vpcs[query("ZoneId")] {
+			vpcs = append(vpcs, map[string]string{"VpcId": vpc})
+		}
+		body = map[string]interface{}{"BindVpcs": map[string]interface{}{"Vpc": vpcs}}
+	case "DescribeZoneRecords":
+		var records []privateZoneRecord
+		for _, record := range f.records[query("ZoneId")] {
+			if record.Rr == query("Keyword") || (query("SearchMode") == "LIKE" && strings.Contains(record.Rr, query("Keyword"))) {
+				records = append(records, record)
+			}
+		}
+		body = map[string]interface{}{"TotalItems": len(records), "Records": map[string]interface{}{"Record": records}}
+	case "AddZoneRecord":
+		f.nextID++
+		f.records[query("ZoneId")] = append(f.records[query("ZoneId")], privateZoneRecord{
+			RecordId: f.nextID,
+			Rr:       query("Rr"),
+			Type:     query("Type"),
+			Value:    query("Value"),
+			Status:   "ENABLE",
+		})
+		body = map[string]interface{}{"RecordId": f.nextID}
+	case "DeleteZoneRecord", "UpdateRecordRemark", "SetZoneRecordStatus":
+		id, _ := strconv.ParseInt(query("RecordId"), 10, 64)
+		for zoneID, records := range f.records {
+			for i, record := range records {
+				if record.RecordId != id {
+					continue
+				}
+				switch action {
+				case "DeleteZoneRecord":
+					f.records[zoneID] = append(records[:i], records[i+1:]...)
+				case "UpdateRecordRemark":
+					records[i].Remark = query("Remark")
+				case "SetZoneRecordStatus":
+					records[i].Status = query("Status")
+				}
+				return map[string]interface{}{"body": map[string]interface{}{}}, nil
+			}
+		}
+		return nil, newSDKError("Record.NotExists", 400)
+	}
+
+	// 与 SDK 一样，响应中的数字为 json.Number
+	raw, _ := json.Marshal(body)
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	var decoded interface{}
+	_ = decoder.Decode(&decoded)
+	return map[string]interface{}{"body": decoded}, nil
+}
+
+func TestPrivateZoneProvider_ResolveDomain(t *testing.T) {
+	tests := []struct {
+		name        string
+		vpcID       string
+		fqdn        string
+		expectZone  string
+		expectRR    string
+		expectError string
+		// expectNotFound 表示错误可以用 ErrZoneNotFound 匹配
+		expectNotFound bool
+	}{
+		{name: "longest bound zone", fqdn: "_acme-challenge.www.dev.corp.internal.", expectZone: "dev.corp.internal", expectRR: "_acme-challenge.www"},
+		{name: "zone apex", fqdn: "corp.internal.", expectZone: "corp.internal", expectRR: "@"},
+		{name: "skips zone not bound to the VPC", vpcID: "vpc-b", fqdn: "_acme-challenge.dev.corp.internal.", expectZone: "corp.internal", expectRR: "_acme-challenge.dev"},
+		{name: "same name zones bound to different VPCs", vpcID: "vpc-b", fqdn: "_acme-challenge.shared.internal.", expectZone: "shared.internal", expectRR: "_acme-challenge"},
+		{name: "ambiguous without VPC", fqdn: "_acme-challenge.shared.internal.", expectError: "set vpcId"},
+		{name: "unbound zone", fqdn: "_acme-challenge.lab.internal.", expectError: "PrivateZone lab.internal covers _acme-challenge.lab.internal but is not bound to any VPC", expectNotFound: true},
+		{name: "no zone", fqdn: "_acme-challenge.example.com.", expectError: "no PrivateZone in this Alibaba Cloud account covers", expectNotFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakePrivateZone()
+			api.addZone("zone-corp", "corp.internal", "vpc-a", "vpc-b")
+			api.addZone("zone-dev", "dev.corp.internal", "vpc-a")
+			api.addZone("zone-shared-a", "shared.internal", "vpc-a")
+			api.addZone("zone-shared-b", "shared.internal", "vpc-b")
+			api.addZone("zone-lab", "lab.internal")
+			provider := newPrivateZoneProvider(api, tt.vpcID)
+
+			zone, rr, err := provider.ResolveDomain(context.Background(), tt.fqdn)
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+				if tt.expectNotFound {
+					assert.ErrorIs(t, err, ErrZoneNotFound)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectZone, zone)
+			assert.Equal(t, tt.expectRR, rr)
+		})
+	}
+}
+
+func TestPrivateZoneProvider_ZoneCached(t *testing.T) {
+	api := newFakePrivateZone()
+	api.addZone("zone-corp", "corp.internal", "vpc-a")
+	provider := newPrivateZoneProvider(api, "")
+
+	for range 3 {
+		id, err := provider.zoneID(context.Background(), "corp.internal")
+		require.NoError(t, err)
+		assert.Equal(t, "zone-corp", id)
+	}
+	assert.Equal(t, 1, api.calls["DescribeZones"])
+	assert.Equal(t, 1, api.calls["DescribeZoneInfo"])
+}
+
+func TestPrivateZoneProvider_NegativeCached(t *testing.T) {
+	api := newFakePrivateZone()
+	api.addZone("zone-corp", "corp.internal", "vpc-a")
+	api.addZone("zone-lab", "lab.internal")
+	now := time.Now()
+	provider := newPrivateZoneProvider(api, "")
+	provider.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 逐级查找父域名时，不存在的 zone 同样缓存
+	for range 3 {
+		zone, _, err := provider.ResolveDomain(ctx, "_acme-challenge.www.corp.internal.")
+		require.NoError(t, err)
+		assert.Equal(t, "corp.internal", zone)
+	}
+	assert.Equal(t, 3, api.calls["DescribeZones"])
+
+	// 未绑定 VPC 的 zone 缓存后仍然报告为未绑定
+	for range 3 {
+		_, _, err := provider.ResolveDomain(ctx, "lab.internal.")
+		assert.ErrorContains(t, err, "PrivateZone lab.internal covers lab.internal but is not bound to any VPC")
+	}
+	assert.Equal(t, 5, api.calls["DescribeZones"])
+	assert.Equal(t, 2, api.calls["DescribeZoneInfo"])
+
+	// 过期后重新查询，新建的 zone 可以被找到
+	api.addZone("zone-www", "www.corp.internal", "vpc-a")
+	now = now.Add(zoneCacheTTL)
+	zone, _, err := provider.ResolveDomain(ctx, "_acme-challenge.www.corp.internal.")
+	require.NoError(t, err)
+	assert.Equal(t, "www.corp.internal", zone)
+}
+
+func TestPrivateZoneProvider_Records(t *testing.T) {
+	api := newFakePrivateZone()
+	api.addZone("zone-corp", "corp.internal", "vpc-a")
+	provider := newPrivateZoneProvider(api, "vpc-a")
+	ctx := context.Background()
+
+	// 添加记录，重复添加时返回同一条记录
+	recordID, err := provider.AddTXTRecord(ctx, "corp.internal", "_acme-challenge", "value-1", RecordOptions{TTL: 30})
+	require.NoError(t, err)
+	again, err := provider.AddTXTRecord(ctx, "corp.internal", "_acme-challenge", "value-1", RecordOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, recordID, again)
+	assert.Equal(t, 1, api.calls["AddZoneRecord"])
+	require.NoError(t, provider.SetRecordRemark(ctx, recordID, recordMarker{Owner: "cluster-a"}.String()))
+
+	// 已暂停的记录被重新启用
+	api.records["zone-corp"][0].Status = "DISABLE"
+	again, err = provider.AddTXTRecord(ctx, "corp.internal", "_acme-challenge", "value-1", RecordOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, recordID, again)
+	assert.Equal(t, "ENABLE", api.records["zone-corp"][0].Status)
+
+	_, err = provider.AddTXTRecord(ctx, "corp.internal", "_acme-challenge", "value-1", RecordOptions{Line: "telecom"})
+	assert.ErrorContains(t, err, "not supported by PrivateZone")
+
+	// 回收孤儿记录时按前缀扫描
+	_, err = provider.AddTXTRecord(ctx, "corp.internal", "_acme-challenge.www", "value-2", RecordOptions{})
+	require.NoError(t, err)
+	scanned, err := provider.DescribeRecords(ctx, "corp.internal", challengeRRPrefix)
+	require.NoError(t, err)
+	assert.Len(t, scanned, 2)
+	assert.Equal(t, recordID, tea.StringValue(scanned[0].RecordId))
+	assert.Contains(t, tea.StringValue(scanned[0].Remark), "owner=cluster-a")
+
+	// 按 key 删除时跳过不属于 owner 的记录
+	require.NoError(t, provider.DeleteRecordsByKey(ctx, "corp.internal", "_acme-challenge", "value-1", "cluster-b"))
+	assert.Len(t, api.records["zone-corp"], 2)
+	require.NoError(t, provider.DeleteRecordsByKey(ctx, "corp.internal", "_acme-challenge", "value-1", "cluster-a"))
+	assert.Len(t, api.records["zone-corp"], 1)
+
+	// 按 ID 删除
+	require.NoError(t, provider.DeleteRecord(ctx, tea.StringValue(scanned[1].RecordId)))
+	assert.Empty(t, api.records["zone-corp"])
+	assert.ErrorContains(t, provider.DeleteRecord(ctx, recordID), "failed to delete zone record")
+}
+
+func TestPrivateZoneProvider_UnboundZone(t *testing.T) {
+	api := newFakePrivateZone()
+	api.addZone("zone-corp", "corp.internal", "vpc-a")
+	provider := newPrivateZoneProvider(api, "vpc-b")
+
+	_, err := provider.AddTXTRecord(context.Background(), "corp.internal", "_acme-challenge", "value-1", RecordOptions{})
+	assert.ErrorContains(t, err, "no PrivateZone named corp.internal is bound to VPC vpc-b")
+	assert.Zero(t, api.calls["AddZoneRecord"])
+}
@@ -0,0 +1,313 @@
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// defaultAccount 是 webhook 默认凭据链在限流器中使用的账号标识
+	defaultAccount = "default"
+	// rateLimitLogThreshold 是需要记录日志的最短排队时间
+	rateLimitLogThreshold = 10 * time.Millisecond
+)
+
+// rateLimitConfig 是 webhook 级别的 AliDNS API 限流配置，0 表示不限制
+type rateLimitConfig struct {
+	// qps 和 burst 限制所有账号合计的请求速率
+	qps   float64
+	burst int
+	// accountQPS 和 accountBurst 限制每个 AccessKey 或 RAM 角色所属账号的请求速率
+	accountQPS   float64
+	accountBurst int
+	// maxInFlight 限制同时进行中的请求数
+	maxInFlight int
+}
+
+// enabled 判断是否配置了任意一种限制
+func (c rateLimitConfig) enabled() bool {
+	return c.qps > 0 || c.accountQPS > 0 || c.maxInFlight > 0
+}
+
+// rateLimitConfigFromEnv 从环境变量读取限流配置
+func rateLimitConfigFromEnv() (rateLimitConfig, error) {
+	var cfg rateLimitConfig
+	var err error
+	if cfg.qps, err = floatEnv("ALIDNS_RATE_LIMIT_QPS"); err != nil {
+		return cfg, err
+	}
+	if cfg.burst, err = intEnv("ALIDNS_RATE_LIMIT_BURST"); err != nil {
+		return cfg, err
+	}
+	if cfg.accountQPS, err = floatEnv("ALIDNS_ACCOUNT_RATE_LIMIT_QPS"); err != nil {
+		return cfg, err
+	}
+	if cfg.accountBurst, err = intEnv("ALIDNS_ACCOUNT_RATE_LIMIT_BURST"); err != nil {
+		return cfg, err
+	}
+	if cfg.maxInFlight, err = intEnv("ALIDNS_MAX_IN_FLIGHT"); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func floatEnv(name string) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative number", name, value)
+	}
+	return f, nil
+}
+
+func intEnv(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", name, value)
+	}
+	return i, nil
+}
+
+// newTokenBucket 创建令牌桶，burst 未配置时取 qps 向上取整
+func newTokenBucket(qps float64, burst int) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(qps))
+	}
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// rateLimiter 在所有 DNSProvider 之间共享，限制发往 AliDNS 的请求
+type rateLimiter struct {
+	cfg    rateLimitConfig
+	global *rate.Limiter
+	// inFlight 是并发请求的信号量，为 nil 时不限制
+	inFlight chan struct{}
+
+	mu       sync.Mutex
+	accounts map[string]*rate.Limiter
+}
+
+// newRateLimiter 根据配置创建限流器，未配置任何限制时返回 nil
+func newRateLimiter(cfg rateLimitConfig) *rateLimiter {
+	if !cfg.enabled() {
+		return nil
+	}
+	l := &rateLimiter{
+		cfg:      cfg,
+		global:   newTokenBucket(cfg.qps, cfg.burst),
+		accounts: map[string]*rate.Limiter{},
+	}
+	if cfg.maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, cfg.maxInFlight)
+	}
+	return l
+}
+
+// wrap 返回经过限流的 client，l 为 nil 时原样返回
+func (l *rateLimiter) wrap(client AliDNSClient, account string) AliDNSClient {
+	if l == nil {
+		return client
+	}
+	return &rateLimitedClient{client: client, limiter: l, account: account}
+}
+
+// wrapPrivateZone 返回经过限流的 PrivateZone client，l 为 nil 时原样返回
+func (l *rateLimiter) wrapPrivateZone(client PrivateZoneClient, account string) PrivateZoneClient {
+	if l == nil {
+		return client
+	}
+	return &rateLimitedPrivateZoneClient{client: client, limiter: l, account: account}
+}
+
+// accountBucket 返回 account 对应的令牌桶
+func (l *rateLimiter) accountBucket(account string) *rate.Limiter {
+	if l.cfg.accountQPS <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.accounts[account]
+	if !ok {
+		bucket = newTokenBucket(l.cfg.accountQPS, l.cfg.accountBurst)
+		l.accounts[account] = bucket
+	}
+	return bucket
+}
+
+// acquire 等待令牌和并发名额，返回释放并发名额的函数。
+// AliDNSClient 的方法不带 context，等待跟随 runtime 所属调用的 context，
+// 最长等待时间取本次调用的建连加读超时，这两个值由 runtimeOptions 根据 context 的截止时间计算。
+// 调用方在等待期间取消时返回错误，避免调用方放弃后仍然发出增删记录等请求
+func (l *rateLimiter) acquire(action, account string, runtime *util.RuntimeOptions) (func(), error) {
+	timeout := defaultConnectTimeout + defaultReadTimeout
+	if runtime != nil && runtime.ConnectTimeout != nil && runtime.ReadTimeout != nil {
+		timeout = time.Duration(tea.IntValue(runtime.ConnectTimeout)+tea.IntValue(runtime.ReadTimeout)) * time.Millisecond
+	}
+	parent := runtimeContext(runtime)
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, bucket := range []*rate.Limiter{l.global, l.accountBucket(account)} {
+		if bucket == nil {
+			continue
+		}
+		if err := bucket.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit exceeded for %s: %w", action, err)
+		}
+	}
+
+	release := func() {}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+			release = func() { <-l.inFlight }
+		case <-ctx.Done():
+			return nil, fmt.Errorf("too many in-flight AliDNS requests for %s: %w", action, ctx.Err())
+		}
+	}
+
+	// 拿到名额时调用方可能已经取消
+	if err := parent.Err(); err != nil {
+		release()
+		return nil, fmt.Errorf("skipping %s: %w", action, err)
+	}
+
+	wait := time.Since(start)
+	rateLimiterWait.WithLabelValues(action).Observe(wait.Seconds())
+	if wait >= rateLimitLogThreshold {
+		slog.Info("Waited for AliDNS rate limiter",
+			"action", action,
+			"account", account,
+			"wait", wait,
+		)
+	}
+	return release, nil
+}
+
+// roleAccount 从 RAM 角色 ARN（acs:ram::<account>:role/<name>）中取出账号 ID
+func roleAccount(roleArn string) string {
+	parts := strings.Split(roleArn, ":")
+	if len(parts) >= 5 && parts[3] != "" {
+		return parts[3]
+	}
+	return roleArn
+}
+
+// rateLimitedClient 在调用 AliDNSClient 前经过 rateLimiter
+type rateLimitedClient struct {
+	client  AliDNSClient
+	limiter *rateLimiter
+	account string
+}
+
+func (c *rateLimitedClient) AddDomainRecordWithOptions(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+	release, err := c.limiter.acquire("AddDomainRecord", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.AddDomainRecordWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) DeleteDomainRecordWithOptions(request *alidns.DeleteDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.DeleteDomainRecordResponse, error) {
+	release, err := c.limiter.acquire("DeleteDomainRecord", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.DeleteDomainRecordWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) DescribeDomainRecordsWithOptions(request *alidns.DescribeDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainRecordsResponse, error) {
+	release, err := c.limiter.acquire("DescribeDomainRecords", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.DescribeDomainRecordsWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) DescribeDomainsWithOptions(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+	release, err := c.limiter.acquire("DescribeDomains", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.DescribeDomainsWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) DescribeDomainInfoWithOptions(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+	release, err := c.limiter.acquire("DescribeDomainInfo", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.DescribeDomainInfoWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) DescribeSubDomainRecordsWithOptions(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+	release, err := c.limiter.acquire("DescribeSubDomainRecords", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.DescribeSubDomainRecordsWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) SetDomainRecordStatusWithOptions(request *alidns.SetDomainRecordStatusRequest, runtime *util.RuntimeOptions) (*alidns.SetDomainRecordStatusResponse, error) {
+	release, err := c.limiter.acquire("SetDomainRecordStatus", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.SetDomainRecordStatusWithOptions(request, runtime)
+}
+
+func (c *rateLimitedClient) UpdateDomainRecordRemarkWithOptions(request *alidns.UpdateDomainRecordRemarkRequest, runtime *util.RuntimeOptions) (*alidns.UpdateDomainRecordRemarkResponse, error) {
+	release, err := c.limiter.acquire("UpdateDomainRecordRemark", c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.UpdateDomainRecordRemarkWithOptions(request, runtime)
+}
+
+// rateLimitedPrivateZoneClient 在调用 PrivateZoneClient 前经过 rateLimiter
+type rateLimitedPrivateZoneClient struct {
+	client  PrivateZoneClient
+	limiter *rateLimiter
+	account string
+}
+
+func (c *rateLimitedPrivateZoneClient) CallApi(params *openapiutil.Params, request *openapiutil.OpenApiRequest, runtime *util.RuntimeOptions) (map[string]interface{}, error) {
+	release, err := c.limiter.acquire(tea.StringValue(params.Action), c.account, runtime)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.client.CallApi(params, request, runtime)
+}
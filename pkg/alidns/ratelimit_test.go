@@ -0,0 +1,177 @@
+package alidns
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/credentials-go/credentials/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitConfigFromEnv(t *testing.T) {
+	mustSetEnv(t, "ALIDNS_RATE_LIMIT_QPS", "20")
+	mustSetEnv(t, "ALIDNS_RATE_LIMIT_BURST", "5")
+	mustSetEnv(t, "ALIDNS_ACCOUNT_RATE_LIMIT_QPS", "2.5")
+	mustSetEnv(t, "ALIDNS_ACCOUNT_RATE_LIMIT_BURST", "")
+	mustSetEnv(t, "ALIDNS_MAX_IN_FLIGHT", "8")
+	defer func() {
+		for _, name := range []string{"ALIDNS_RATE_LIMIT_QPS", "ALIDNS_RATE_LIMIT_BURST", "ALIDNS_ACCOUNT_RATE_LIMIT_QPS", "ALIDNS_ACCOUNT_RATE_LIMIT_BURST", "ALIDNS_MAX_IN_FLIGHT"} {
+			mustUnsetEnv(t, name)
+		}
+	}()
+
+	cfg, err := rateLimitConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, rateLimitConfig{qps: 20, burst: 5, accountQPS: 2.5, maxInFlight: 8}, cfg)
+
+	// 非法值返回错误
+	mustSetEnv(t, "ALIDNS_MAX_IN_FLIGHT", "-1")
+	_, err = rateLimitConfigFromEnv()
+	assert.ErrorContains(t, err, "ALIDNS_MAX_IN_FLIGHT")
+
+	mustSetEnv(t, "ALIDNS_MAX_IN_FLIGHT", "")
+	mustSetEnv(t, "ALIDNS_RATE_LIMIT_QPS", "fast")
+	_, err = rateLimitConfigFromEnv()
+	assert.ErrorContains(t, err, "ALIDNS_RATE_LIMIT_QPS")
+}
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	limiter := newRateLimiter(rateLimitConfig{})
+	assert.Nil(t, limiter)
+
+	// 未启用限流时直接使用原始 client
+	client := &MockAliDNSClient{}
+	assert.Same(t, client, limiter.wrap(client, defaultAccount))
+}
+
+func TestRateLimitedClient_QPS(t *testing.T) {
+	limiter := newRateLimiter(rateLimitConfig{qps: 50, burst: 1})
+	client := limiter.wrap(&MockAliDNSClient{}, defaultAccount)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := client.DescribeDomainsWithOptions(&alidns.DescribeDomainsRequest{}, &util.RuntimeOptions{})
+		require.NoError(t, err)
+	}
+	// burst 为 1 时，后两次调用各需等待约 20ms
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+}
+
+func TestRateLimitedClient_PerAccount(t *testing.T) {
+	limiter := newRateLimiter(rateLimitConfig{accountQPS: 0.001, accountBurst: 1})
+	mock := &MockAliDNSClient{}
+	runtime := &util.RuntimeOptions{ConnectTimeout: tea.Int(10), ReadTimeout: tea.Int(10)}
+
+	// 每个账号各自有一个令牌
+	_, err := limiter.wrap(mock, "account-a").DescribeDomainsWithOptions(&alidns.DescribeDomainsRequest{}, runtime)
+	require.NoError(t, err)
+	_, err = limiter.wrap(mock, "account-b").DescribeDomainsWithOptions(&alidns.DescribeDomainsRequest{}, runtime)
+	require.NoError(t, err)
+
+	// 同一账号的令牌用完后，在超时内拿不到新令牌
+	_, err = limiter.wrap(mock, "account-a").DescribeDomainsWithOptions(&alidns.DescribeDomainsRequest{}, runtime)
+	assert.ErrorContains(t, err, "rate limit exceeded for DescribeDomains")
+}
+
+func TestRateLimitedClient_MaxInFlight(t *testing.T) {
+	limiter := newRateLimiter(rateLimitConfig{maxInFlight: 2})
+
+	var current, peak atomic.Int32
+	mock := &MockAliDNSClient{
+		DescribeDomainsFunc: func(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+			n := current.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			current.Add(-1)
+			return &alidns.DescribeDomainsResponse{}, nil
+		},
+	}
+	client := limiter.wrap(mock, defaultAccount)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.DescribeDomainsWithOptions(&alidns.DescribeDomainsRequest{}, &util.RuntimeOptions{})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestRateLimitedClient_CallerCancelled(t *testing.T) {
+	limiter := newRateLimiter(rateLimitConfig{maxInFlight: 1})
+	var added atomic.Int32
+	client := limiter.wrap(&MockAliDNSClient{
+		AddDomainRecordFunc: func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+			added.Add(1)
+			return &alidns.AddDomainRecordResponse{}, nil
+		},
+	}, defaultAccount)
+
+	// 占满并发名额，使请求在限流器中排队
+	hold, err := limiter.acquire("AddDomainRecord", defaultAccount, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error, 1)
+	go func() {
+		_, err := callWithContext(ctx, func(runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+			response, err := client.AddDomainRecordWithOptions(&alidns.AddDomainRecordRequest{}, runtime)
+			finished <- err
+			return response, err
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+
+	// 调用方取消后释放名额，排队中的请求不会再发出
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	hold()
+
+	select {
+	case err := <-finished:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("queued request did not return after the caller cancelled")
+	}
+	assert.Equal(t, int32(0), added.Load())
+}
+
+func TestRoleAccount(t *testing.T) {
+	assert.Equal(t, "1234567890", roleAccount("acs:ram::1234567890:role/dns-admin"))
+	assert.Equal(t, "not-an-arn", roleAccount("not-an-arn"))
+}
+
+func TestSolver_BuildDNSProvider_RateLimited(t *testing.T) {
+	solver := &Solver{limiter: newRateLimiter(rateLimitConfig{qps: 10})}
+	cp, err := providers.NewStaticAKCredentialsProviderBuilder().
+		WithAccessKeyId("ak").
+		WithAccessKeySecret("sk").
+		Build()
+	require.NoError(t, err)
+
+	provider, err := solver.buildDNSProvider(cp, &Config{}, "ak")
+	require.NoError(t, err)
+	p, ok := provider.(*dnsProvider)
+	require.True(t, ok)
+	limited, ok := p.client.(*rateLimitedClient)
+	require.True(t, ok)
+	assert.Equal(t, "ak", limited.account)
+	assert.Same(t, p.client, p.zones.client)
+}
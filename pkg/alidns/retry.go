@@ -0,0 +1,142 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+)
+
+// retryPolicy 控制 AliDNS API 调用失败后的重试
+type retryPolicy struct {
+	// baseDelay 是第一次重试前的等待时间，之后每次翻倍
+	baseDelay time.Duration
+	// maxDelay 是单次等待时间的上限
+	maxDelay time.Duration
+	// budget 是一次调用（包括所有重试）的总时长上限
+	budget time.Duration
+}
+
+// apiRetryPolicy 是所有 AliDNS API 调用使用的重试策略，测试中可替换
+var apiRetryPolicy = retryPolicy{
+	baseDelay: 200 * time.Millisecond,
+	maxDelay:  5 * time.Second,
+	budget:    30 * time.Second,
+}
+
+// permanentErrorCodes 是重试也不会成功的错误码，例如凭据或权限错误
+var permanentErrorCodes = map[string]bool{
+	"InvalidAccessKeyId.NotFound":    true,
+	"InvalidAccessKeyId.Inactive":    true,
+	"InvalidAccessKeyId":             true,
+	"SignatureDoesNotMatch":          true,
+	"InvalidSecurityToken.Expired":   true,
+	"InvalidSecurityToken.Malformed": true,
+	"Forbidden.RAM":                  true,
+	"Forbidden":                      true,
+}
+
+// transientErrorCodes 是服务端限流或暂时不可用的错误码
+var transientErrorCodes = map[string]bool{
+	"ServiceUnavailable":          true,
+	"ServiceUnavailableTemporary": true,
+	"InternalError":               true,
+	"UnknownError":                true,
+	"LastOperationNotFinished":    true,
+}
+
+// isErrorCode 判断 err 是否为错误码为 code 的 SDK 错误
+func isErrorCode(err error, code string) bool {
+	sdkErr, ok := asSDKError(err)
+	return ok && sdkErr.code == code
+}
+
+// isRetryable 判断 err 是否为可以重试的临时错误
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if sdkErr, ok := asSDKError(err); ok {
+		code := sdkErr.code
+		switch {
+		case permanentErrorCodes[code]:
+			return false
+		case transientErrorCodes[code], strings.HasPrefix(code, "Throttling"):
+			return true
+		}
+		return sdkErr.statusCode >= 500
+	}
+
+	// 连接被重置、提前断开或超时等网络错误
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// delay 返回第 attempt 次重试前的等待时间（从 1 开始），
+// 在指数退避的基础上加入随机抖动，避免多个请求同时重试
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.baseDelay << min(attempt-1, 30)
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// callWithRetry 调用 AliDNS API，遇到限流、5xx 或网络错误时按 apiRetryPolicy 重试，
+// 凭据、权限等永久错误直接返回。SDK 返回的错误被包装为 *APIError
+func callWithRetry[T any](ctx context.Context, action string, call func(runtime *util.RuntimeOptions) (T, error)) (response T, err error) {
+	defer func() { err = newAPIError(action, err) }()
+
+	policy := apiRetryPolicy
+	parent := ctx
+	ctx, cancel := context.WithTimeout(parent, policy.budget)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		response, err := callWithContext(ctx, call)
+		if lastErr != nil && errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+			// 重试期间用完了时间预算，返回最后一次 API 错误
+			return response, lastErr
+		}
+		if err == nil || !isRetryable(err) {
+			return response, err
+		}
+		lastErr = err
+
+		delay := policy.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// 剩余时间不足以再重试一次
+			return response, err
+		}
+
+		apiRetries.WithLabelValues(action).Inc()
+		slog.Warn("Retrying AliDNS API call",
+			"action", action,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return response, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useFastRetries 在测试期间缩短重试等待时间
+func useFastRetries(t *testing.T, budget time.Duration) {
+	t.Helper()
+	previous := apiRetryPolicy
+	apiRetryPolicy = retryPolicy{
+		baseDelay: time.Millisecond,
+		maxDelay:  5 * time.Millisecond,
+		budget:    budget,
+	}
+	t.Cleanup(func() { apiRetryPolicy = previous })
+}
+
+// newSDKError 构造与 SDK 返回值相同的错误
+func newSDKError(code string, statusCode int) error {
+	return tea.NewSDKError(map[string]interface{}{
+		"code":       code,
+		"statusCode": statusCode,
+		"message":    code,
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		expect bool
+	}{
+		{name: "throttling", err: newSDKError("Throttling.User", 400), expect: true},
+		{name: "service unavailable", err: newSDKError("ServiceUnavailable", 503), expect: true},
+		{name: "unknown 5xx", err: newSDKError("SomethingBroke", 502), expect: true},
+		{name: "wrapped throttling", err: fmt.Errorf("failed: %w", newSDKError("Throttling", 400)), expect: true},
+		{name: "invalid access key", err: newSDKError("InvalidAccessKeyId.NotFound", 404), expect: false},
+		{name: "ram forbidden", err: newSDKError("Forbidden.RAM", 403), expect: false},
+		{name: "permanent code with 5xx", err: newSDKError("InvalidAccessKeyId.NotFound", 500), expect: false},
+		{name: "invalid parameter", err: newSDKError("InvalidParameter", 400), expect: false},
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, expect: true},
+		{name: "context canceled", err: context.Canceled, expect: false},
+		{name: "plain error", err: errors.New("boom"), expect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, isRetryable(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	for attempt, limit := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		10: time.Second,
+		64: time.Second,
+	} {
+		d := policy.delay(attempt)
+		assert.GreaterOrEqual(t, d, limit/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, d, limit, "attempt %d", attempt)
+	}
+}
+
+func TestCallWithRetry(t *testing.T) {
+	useFastRetries(t, time.Second)
+
+	tests := []struct {
+		name        string
+		errs        []error
+		expectCalls int
+		expectError bool
+	}{
+		{
+			name:        "success without retry",
+			expectCalls: 1,
+		},
+		{
+			name:        "throttled then success",
+			errs:        []error{newSDKError("Throttling.User", 400), newSDKError("ServiceUnavailable", 503)},
+			expectCalls: 3,
+		},
+		{
+			name:        "permanent error is not retried",
+			errs:        []error{newSDKError("Forbidden.RAM", 403)},
+			expectCalls: 1,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			response, err := callWithRetry(context.Background(), "Test", func(runtime *util.RuntimeOptions) (string, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return "", tt.errs[calls-1]
+				}
+				return "ok", nil
+			})
+
+			assert.Equal(t, tt.expectCalls, calls)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "ok", response)
+			}
+		})
+	}
+}
+
+func TestCallWithRetry_Budget(t *testing.T) {
+	useFastRetries(t, 50*time.Millisecond)
+
+	calls := 0
+	start := time.Now()
+	_, err := callWithRetry(context.Background(), "Test", func(runtime *util.RuntimeOptions) (string, error) {
+		calls++
+		return "", newSDKError("Throttling.User", 400)
+	})
+
+	// 超出时间预算后返回最后一次的 API 错误
+	var sdkErr *tea.SDKError
+	require.ErrorAs(t, err, &sdkErr)
+	assert.Equal(t, "Throttling.User", tea.StringValue(sdkErr.Code))
+	assert.Greater(t, calls, 1)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestAddTXTRecord_RetriesThrottling(t *testing.T) {
+	useFastRetries(t, time.Second)
+
+	describeCalls := 0
+	mockClient := &MockAliDNSClient{
+		DescribeSubDomainRecordsFunc: func(request *alidns.DescribeSubDomainRecordsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeSubDomainRecordsResponse, error) {
+			describeCalls++
+			if describeCalls == 1 {
+				return nil, newSDKError("Throttling.User", 400)
+			}
+			return &alidns.DescribeSubDomainRecordsResponse{
+				Body: &alidns.DescribeSubDomainRecordsResponseBody{TotalCount: tea.Int64(0)},
+			}, nil
+		},
+	}
+
+	provider := newDNSProviderWithClient(mockClient)
+	recordID, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "mock-record-id", recordID)
+	assert.Equal(t, 2, describeCalls)
+}
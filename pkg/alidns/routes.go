@@ -0,0 +1,147 @@
+package alidns
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cert-manager/cert-manager/pkg/issuer/acme/dns/util"
+	"golang.org/x/net/idna"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// routesConfigMapKey 是路由表 ConfigMap 中保存路由的 key
+const routesConfigMapKey = "routes.yaml"
+
+// ZoneRoute 将 zone 后缀映射到一组凭据配置，字段与 Issuer 的 Config 相同，
+// 引用的 Secret 和 ServiceAccount 位于 webhook 所在的 namespace
+type ZoneRoute struct {
+	// Zone 是匹配的域名后缀，例如 example.com 同时匹配 dev.example.com
+	Zone   string `json:"zone"`
+	Config `json:",inline"`
+}
+
+// routesFile 是路由表 ConfigMap 中 routes.yaml 的格式
+type routesFile struct {
+	Routes []ZoneRoute `json:"routes"`
+}
+
+// routeTable 是可以在运行时替换的路由表
+type routeTable struct {
+	mu sync.RWMutex
+	// routes 按 zone 长度倒序排列，保证最长后缀优先匹配
+	routes []ZoneRoute
+}
+
+// parseRoutes 解析并校验路由表
+func parseRoutes(data string) ([]ZoneRoute, error) {
+	var file routesFile
+	if err := yaml.UnmarshalStrict([]byte(data), &file); err != nil {
+		return nil, fmt.Errorf("error decoding routes: %w", err)
+	}
+
+	seen := make(map[string]bool, len(file.Routes))
+	for i := range file.Routes {
+		route := &file.Routes[i]
+		zone := normalizeZone(route.Zone)
+		if zone == "" {
+			return nil, fmt.Errorf("route %d: zone must be set", i)
+		}
+		if seen[zone] {
+			return nil, fmt.Errorf("route %d: duplicate zone %q", i, zone)
+		}
+		if err := route.validateBackend(); err != nil {
+			return nil, fmt.Errorf("route %d: %w", i, err)
+		}
+		seen[zone] = true
+		route.Zone = zone
+	}
+
+	sort.SliceStable(file.Routes, func(i, j int) bool {
+		return len(file.Routes[i].Zone) > len(file.Routes[j].Zone)
+	})
+	return file.Routes, nil
+}
+
+// normalizeZone 统一 zone 格式，与 extractDomainAndRR 返回的 domain 保持一致
+func normalizeZone(zone string) string {
+	zone = strings.ToLower(util.UnFqdn(strings.TrimSpace(zone)))
+	if uZone, err := idna.ToUnicode(zone); err == nil {
+		zone = uZone
+	}
+	return zone
+}
+
+// set 替换整个路由表
+func (t *routeTable) set(routes []ZoneRoute) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes = routes
+}
+
+// match 返回匹配 domain 的最长后缀路由，没有匹配时返回 nil
+func (t *routeTable) match(domain string) *ZoneRoute {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	domain = normalizeZone(domain)
+	for i := range t.routes {
+		zone := t.routes[i].Zone
+		if domain == zone || strings.HasSuffix(domain, "."+zone) {
+			route := t.routes[i]
+			return &route
+		}
+	}
+	return nil
+}
+
+// watchRoutes 监听路由表 ConfigMap，变更后立即生效，无需重启 webhook。
+// ConfigMap 被删除时清空路由表；内容无效时保留上一次的路由表。
+func (s *Solver) watchRoutes(namespace, name string, stopCh <-chan struct{}) {
+	factory := informers.NewSharedInformerFactoryWithOptions(s.kubeClient, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+
+	load := func(obj interface{}) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok || cm.Name != name {
+			return
+		}
+		routes, err := parseRoutes(cm.Data[routesConfigMapKey])
+		if err != nil {
+			slog.Error("Failed to load zone routes, keeping previous routes",
+				"configMap", namespace+"/"+name,
+				"error", err,
+			)
+			return
+		}
+		s.routes.set(routes)
+		slog.Info("Loaded zone routes", "configMap", namespace+"/"+name, "routes", len(routes))
+	}
+
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	_, _ = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    load,
+		UpdateFunc: func(_, obj interface{}) { load(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if cm, ok := obj.(*corev1.ConfigMap); ok && cm.Name == name {
+				s.routes.set(nil)
+				slog.Info("Zone routes ConfigMap deleted, using default credentials", "configMap", namespace+"/"+name)
+			}
+		},
+	})
+	factory.Start(stopCh)
+}
@@ -0,0 +1,170 @@
+package alidns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	credential "github.com/aliyun/credentials-go/credentials"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testRoutes = `
+routes:
+  - zone: example.com
+    accessKeyIdSecretRef: {name: account-a, key: id}
+    accessKeySecretSecretRef: {name: account-a, key: secret}
+  - zone: dev.example.com.
+    regionId: cn-shanghai
+    accessKeyIdSecretRef: {name: account-b, key: id}
+    accessKeySecretSecretRef: {name: account-b, key: secret}
+  - zone: xn--fiq228c.com
+    roleArn: acs:ram::1234567890:role/dns
+`
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes(testRoutes)
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+	// 按 zone 长度倒序
+	assert.Equal(t, "dev.example.com", routes[0].Zone)
+	assert.Equal(t, "cn-shanghai", routes[0].RegionID)
+	assert.Equal(t, "account-b", routes[0].AccessKeyIDSecretRef.Name)
+
+	_, err = parseRoutes("routes:\n  - regionId: cn-hangzhou\n")
+	assert.ErrorContains(t, err, "zone must be set")
+
+	_, err = parseRoutes("routes:\n  - zone: example.com\n  - zone: Example.com.\n")
+	assert.ErrorContains(t, err, "duplicate zone")
+
+	_, err = parseRoutes("routes:\n  - zone: example.com\n    unknownField: true\n")
+	assert.Error(t, err)
+
+	_, err = parseRoutes("routes:\n  - zone: example.com\n    backend: route53\n")
+	assert.ErrorContains(t, err, "route 0: invalid backend")
+}
+
+func TestRouteTable_Match(t *testing.T) {
+	routes, err := parseRoutes(testRoutes)
+	require.NoError(t, err)
+	table := &routeTable{}
+	table.set(routes)
+
+	tests := []struct {
+		domain     string
+		expectZone string
+	}{
+		{domain: "example.com", expectZone: "example.com"},
+		{domain: "www.example.com", expectZone: "example.com"},
+		{domain: "dev.example.com", expectZone: "dev.example.com"},
+		{domain: "api.dev.example.com", expectZone: "dev.example.com"},
+		{domain: "中文.com", expectZone: "中文.com"},
+		{domain: "notexample.com", expectZone: ""},
+		{domain: "example.org", expectZone: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			route := table.match(tt.domain)
+			if tt.expectZone == "" {
+				assert.Nil(t, route)
+				return
+			}
+			require.NotNil(t, route)
+			assert.Equal(t, tt.expectZone, route.Zone)
+		})
+	}
+}
+
+func TestSolver_ResolveProvider_Routes(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		newTestSecret("webhook", "account-a", map[string]string{"id": "ak-a", "secret": "sk-a"}),
+		newTestSecret("webhook", "account-b", map[string]string{"id": "ak-b", "secret": "sk-b"}),
+	)
+	ambient := &MockDNSProvider{}
+
+	type built struct {
+		accessKeyID string
+		regionID    string
+	}
+	var got *built
+	solver := &Solver{
+		kubeClient:  kubeClient,
+		dnsProvider: ambient,
+		namespace:   "webhook",
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			model, err := cred.GetCredential()
+			require.NoError(t, err)
+			got = &built{accessKeyID: *model.AccessKeyId, regionID: regionID}
+			return &MockDNSProvider{}, nil
+		},
+	}
+	routes, err := parseRoutes(testRoutes)
+	require.NoError(t, err)
+	solver.routes.set(routes)
+
+	ch := &v1alpha1.ChallengeRequest{ResourceNamespace: "team-a", AllowAmbientCredentials: true}
+
+	_, err = solver.resolveProvider(context.Background(), ch, "api.dev.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, &built{accessKeyID: "ak-b", regionID: "cn-shanghai"}, got)
+
+	_, err = solver.resolveProvider(context.Background(), ch, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, &built{accessKeyID: "ak-a"}, got)
+
+	// 没有匹配的路由时回退到默认 provider
+	got = nil
+	provider, err := solver.resolveProvider(context.Background(), ch, "example.org")
+	require.NoError(t, err)
+	assert.Same(t, ambient, provider)
+	assert.Nil(t, got)
+
+	// 路由表属于 webhook 自身凭据，不允许 ambient credentials 时不可使用
+	_, err = solver.resolveProvider(context.Background(), &v1alpha1.ChallengeRequest{ResourceNamespace: "team-a"}, "example.com")
+	assert.ErrorContains(t, err, "ambient credentials are not allowed")
+}
+
+func TestSolver_WatchRoutes(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "webhook", Name: "alidns-routes"},
+		Data:       map[string]string{routesConfigMapKey: "routes:\n  - zone: example.com\n"},
+	}
+	kubeClient := fake.NewSimpleClientset(cm)
+	solver := &Solver{kubeClient: kubeClient}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	solver.watchRoutes("webhook", "alidns-routes", stopCh)
+
+	assert.Eventually(t, func() bool {
+		return solver.routes.match("www.example.com") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 更新 ConfigMap 后无需重启即可生效
+	cm = cm.DeepCopy()
+	cm.Data[routesConfigMapKey] = "routes:\n  - zone: example.org\n"
+	_, err := kubeClient.CoreV1().ConfigMaps("webhook").Update(context.Background(), cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return solver.routes.match("www.example.com") == nil && solver.routes.match("www.example.org") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 无效内容保留上一次的路由表
+	cm = cm.DeepCopy()
+	cm.Data[routesConfigMapKey] = "routes:\n  - regionId: cn-hangzhou\n"
+	_, err = kubeClient.CoreV1().ConfigMaps("webhook").Update(context.Background(), cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.NotNil(t, solver.routes.match("www.example.org"))
+
+	// 删除 ConfigMap 后回退到默认凭据
+	require.NoError(t, kubeClient.CoreV1().ConfigMaps("webhook").Delete(context.Background(), "alidns-routes", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		return solver.routes.match("www.example.org") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
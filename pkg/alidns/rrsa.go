@@ -0,0 +1,192 @@
+package alidns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/credentials-go/credentials/providers"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// rrsaAudience 是 ACK RRSA 使用的 OIDC Token audience
+	rrsaAudience = "sts.aliyuncs.com"
+	// rrsaTokenExpirationSeconds 是通过 TokenRequest 申请的 OIDC Token 有效期，
+	// Token 只用于立即交换 STS 凭证，使用 Kubernetes 允许的最小值
+	rrsaTokenExpirationSeconds = 600
+	defaultSTSEndpoint         = "sts.aliyuncs.com"
+	stsAPIVersion              = "2015-04-01"
+)
+
+// rrsaRequestTimeout 是申请 OIDC Token 和交换 STS 凭证的总超时，测试中可替换。
+// GetCredentials 持有锁期间不能无限等待，否则同一角色的后续调用都会被阻塞
+var rrsaRequestTimeout = defaultConnectTimeout + defaultReadTimeout
+
+// oidcCredentialsProvider 为指定 ServiceAccount 申请 OIDC Token，
+// 并通过 AssumeRoleWithOIDC 换取 STS 临时凭证
+type oidcCredentialsProvider struct {
+	// tokenSource 返回用于交换 STS 凭证的 OIDC Token
+	tokenSource     func(ctx context.Context) (string, error)
+	roleArn         string
+	oidcProviderArn string
+	roleSessionName string
+	durationSeconds int
+	// stsURL 是 STS 服务地址，例如 https://sts.aliyuncs.com
+	stsURL     string
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	credentials *providers.Credentials
+	expiration  time.Time
+}
+
+// assumeRoleWithOIDCResponse 是 AssumeRoleWithOIDC 的响应
+type assumeRoleWithOIDCResponse struct {
+	RequestId   string `json:"RequestId"`
+	Code        string `json:"Code"`
+	Message     string `json:"Message"`
+	Credentials *struct {
+		AccessKeyId     string `json:"AccessKeyId"`
+		AccessKeySecret string `json:"AccessKeySecret"`
+		SecurityToken   string `json:"SecurityToken"`
+		Expiration      string `json:"Expiration"`
+	} `json:"Credentials"`
+}
+
+// serviceAccountTokenSource 通过 TokenRequest API 为 ServiceAccount 申请 RRSA 使用的 OIDC Token
+func serviceAccountTokenSource(client kubernetes.Interface, namespace, name string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		expirationSeconds := int64(rrsaTokenExpirationSeconds)
+		tr, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences:         []string{rrsaAudience},
+				ExpirationSeconds: &expirationSeconds,
+			},
+		}, metav1.CreateOptions{})
+		if apierrors.IsForbidden(err) {
+			return "", fmt.Errorf("failed to request token for service account %s/%s: %w; "+
+				"install the chart with aliyunAuth.namespaceRRSA.enabled=true to allow per-namespace RRSA", namespace, name, err)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to request token for service account %s/%s: %w", namespace, name, err)
+		}
+		if tr.Status.Token == "" {
+			return "", fmt.Errorf("empty token returned for service account %s/%s", namespace, name)
+		}
+		return tr.Status.Token, nil
+	}
+}
+
+// GetCredentials 返回缓存的 STS 凭证，临近过期时重新交换
+func (p *oidcCredentialsProvider) GetCredentials() (*providers.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.credentials != nil && time.Until(p.expiration) > sessionExpiryMargin {
+		return p.credentials, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rrsaRequestTimeout)
+	defer cancel()
+
+	token, err := p.tokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cc, expiration, err := p.assumeRole(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	p.credentials, p.expiration = cc, expiration
+	return cc, nil
+}
+
+// GetProviderName 实现 providers.CredentialsProvider
+func (p *oidcCredentialsProvider) GetProviderName() string {
+	return "rrsa_oidc_role_arn"
+}
+
+// assumeRole 调用 STS AssumeRoleWithOIDC 交换临时凭证，该接口无需签名
+func (p *oidcCredentialsProvider) assumeRole(ctx context.Context, token string) (*providers.Credentials, time.Time, error) {
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithOIDC")
+	query.Set("Version", stsAPIVersion)
+	query.Set("Format", "JSON")
+	query.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+
+	form := url.Values{}
+	form.Set("RoleArn", p.roleArn)
+	form.Set("OIDCProviderArn", p.oidcProviderArn)
+	form.Set("OIDCToken", token)
+	form.Set("RoleSessionName", p.roleSessionName)
+	form.Set("DurationSeconds", strconv.Itoa(p.durationSeconds))
+
+	httpClient := p.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.stsURL+"/?"+query.Encode(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to build AssumeRoleWithOIDC request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to call AssumeRoleWithOIDC: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read AssumeRoleWithOIDC response: %w", err)
+	}
+
+	var data assumeRoleWithOIDCResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode AssumeRoleWithOIDC response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("AssumeRoleWithOIDC for %s failed: %s: %s (RequestId: %s)", p.roleArn, data.Code, data.Message, data.RequestId)
+	}
+	if data.Credentials == nil || data.Credentials.AccessKeyId == "" || data.Credentials.AccessKeySecret == "" || data.Credentials.SecurityToken == "" {
+		return nil, time.Time{}, fmt.Errorf("AssumeRoleWithOIDC for %s returned no credentials (RequestId: %s)", p.roleArn, data.RequestId)
+	}
+
+	expiration, err := time.Parse("2006-01-02T15:04:05Z", data.Credentials.Expiration)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid expiration in AssumeRoleWithOIDC response: %w", err)
+	}
+
+	return &providers.Credentials{
+		AccessKeyId:     data.Credentials.AccessKeyId,
+		AccessKeySecret: data.Credentials.AccessKeySecret,
+		SecurityToken:   data.Credentials.SecurityToken,
+		ProviderName:    p.GetProviderName(),
+	}, expiration, nil
+}
+
+// getSTSURL 返回 STS 服务地址，与 credentials-go 一样支持
+// ALIBABA_CLOUD_STS_REGION 和 ALIBABA_CLOUD_VPC_ENDPOINT_ENABLED
+func getSTSURL() string {
+	region := os.Getenv("ALIBABA_CLOUD_STS_REGION")
+	if region == "" {
+		return "https://" + defaultSTSEndpoint
+	}
+	prefix := "sts"
+	if os.Getenv("ALIBABA_CLOUD_VPC_ENDPOINT_ENABLED") == "true" {
+		prefix = "sts-vpc"
+	}
+	return fmt.Sprintf("https://%s.%s.aliyuncs.com", prefix, region)
+}
@@ -0,0 +1,176 @@
+package alidns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	credential "github.com/aliyun/credentials-go/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeSTSServer 模拟 STS AssumeRoleWithOIDC 接口
+func newFakeSTSServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRoleWithOIDC", r.URL.Query().Get("Action"))
+		assert.Equal(t, "acs:ram::1234567890:oidc-provider/ack-rrsa-test", r.PostForm.Get("OIDCProviderArn"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("OIDCToken") != "token-for-team-a/dns-solver" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"RequestId":"req-1","Code":"AuthenticationFail.OIDCToken.Invalid","Message":"invalid token"}`))
+			return
+		}
+		expiration := time.Now().Add(time.Hour).UTC().Format("2006-01-02T15:04:05Z")
+		_, _ = w.Write([]byte(`{"RequestId":"req-2","Credentials":{"AccessKeyId":"STS.ak","AccessKeySecret":"sk","SecurityToken":"token","Expiration":"` + expiration + `"}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newFakeTokenClient(t *testing.T) *fake.Clientset {
+	t.Helper()
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateAction)
+		if create.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tr := create.GetObject().(*authenticationv1.TokenRequest)
+		assert.Equal(t, []string{rrsaAudience}, tr.Spec.Audiences)
+		tr.Status.Token = "token-for-" + create.GetNamespace() + "/" + create.(k8stesting.CreateActionImpl).Name
+		return true, tr, nil
+	})
+	return client
+}
+
+func TestOIDCCredentialsProvider(t *testing.T) {
+	var calls int32
+	server := newFakeSTSServer(t, &calls)
+	client := newFakeTokenClient(t)
+
+	p := &oidcCredentialsProvider{
+		tokenSource:     serviceAccountTokenSource(client, "team-a", "dns-solver"),
+		roleArn:         "acs:ram::1234567890:role/team-a-dns",
+		oidcProviderArn: "acs:ram::1234567890:oidc-provider/ack-rrsa-test",
+		roleSessionName: defaultRoleSessionName,
+		durationSeconds: 3600,
+		stsURL:          server.URL,
+	}
+
+	cc, err := p.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "STS.ak", cc.AccessKeyId)
+	assert.Equal(t, "token", cc.SecurityToken)
+
+	// 未过期时不会重复交换
+	_, err = p.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// 其他 ServiceAccount 的 Token 被 STS 拒绝
+	other := &oidcCredentialsProvider{
+		tokenSource:     serviceAccountTokenSource(client, "team-b", "dns-solver"),
+		roleArn:         "acs:ram::1234567890:role/team-a-dns",
+		oidcProviderArn: "acs:ram::1234567890:oidc-provider/ack-rrsa-test",
+		roleSessionName: defaultRoleSessionName,
+		durationSeconds: 3600,
+		stsURL:          server.URL,
+	}
+	_, err = other.GetCredentials()
+	assert.ErrorContains(t, err, "AuthenticationFail.OIDCToken.Invalid")
+}
+
+func TestServiceAccountTokenSource_Forbidden(t *testing.T) {
+	// chart 未开启 aliyunAuth.namespaceRRSA.enabled 时 webhook 没有 TokenRequest 权限
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "serviceaccounts/token"}, "dns-solver", nil)
+	})
+
+	_, err := serviceAccountTokenSource(client, "team-a", "dns-solver")(context.Background())
+	assert.ErrorContains(t, err, "aliyunAuth.namespaceRRSA.enabled=true")
+	assert.True(t, apierrors.IsForbidden(err))
+}
+
+func TestOIDCCredentialsProvider_Timeout(t *testing.T) {
+	previous := rrsaRequestTimeout
+	rrsaRequestTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { rrsaRequestTimeout = previous })
+
+	// STS 一直不返回时，GetCredentials 在超时后返回错误并释放锁
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	p := &oidcCredentialsProvider{
+		tokenSource:     serviceAccountTokenSource(newFakeTokenClient(t), "team-a", "dns-solver"),
+		roleArn:         "acs:ram::1234567890:role/team-a-dns",
+		oidcProviderArn: "acs:ram::1234567890:oidc-provider/ack-rrsa-test",
+		roleSessionName: defaultRoleSessionName,
+		durationSeconds: 3600,
+		stsURL:          server.URL,
+	}
+
+	for range 2 {
+		start := time.Now()
+		_, err := p.GetCredentials()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	}
+}
+
+func TestSolver_ProviderFor_RRSA(t *testing.T) {
+	var calls int32
+	server := newFakeSTSServer(t, &calls)
+
+	var gotCredential credential.Credential
+	solver := &Solver{
+		kubeClient:  newFakeTokenClient(t),
+		dnsProvider: &MockDNSProvider{},
+		newDNSProvider: func(cred credential.Credential, regionID string) (DNSProvider, error) {
+			gotCredential = cred
+			return &MockDNSProvider{}, nil
+		},
+		stsURL: server.URL,
+	}
+
+	cfg := &Config{
+		ServiceAccountName: "dns-solver",
+		RoleArn:            "acs:ram::1234567890:role/team-a-dns",
+		OIDCProviderArn:    "acs:ram::1234567890:oidc-provider/ack-rrsa-test",
+	}
+	_, err := solver.providerFor(context.Background(), cfg, "team-a")
+	require.NoError(t, err)
+
+	model, err := gotCredential.GetCredential()
+	require.NoError(t, err)
+	assert.Equal(t, "STS.ak", *model.AccessKeyId)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	_, err = solver.providerFor(context.Background(), &Config{ServiceAccountName: "dns-solver"}, "team-a")
+	assert.ErrorContains(t, err, "roleArn must be set")
+
+	_, err = solver.providerFor(context.Background(), &Config{
+		ServiceAccountName:   "dns-solver",
+		RoleArn:              "acs:ram::1234567890:role/team-a-dns",
+		AccessKeyIDSecretRef: secretRef("alidns", "id"),
+	}, "team-a")
+	assert.ErrorContains(t, err, "cannot be combined")
+}
@@ -0,0 +1,87 @@
+package alidns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+const (
+	// defaultConnectTimeout 是 context 没有更早截止时间时的建连超时
+	defaultConnectTimeout = 5 * time.Second
+	// defaultReadTimeout 是 context 没有更早截止时间时的读超时
+	defaultReadTimeout = 10 * time.Second
+	// operationTimeout 是一次 Present / CleanUp 的总时长上限
+	operationTimeout = 2 * time.Minute
+)
+
+// runtimeOptions 根据 ctx 的截止时间生成本次调用的 SDK 超时设置，
+// 建连和读超时都不会超过 ctx 剩余的时间
+func runtimeOptions(ctx context.Context) (*util.RuntimeOptions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	connectTimeout, readTimeout := defaultConnectTimeout, defaultReadTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		connectTimeout = min(connectTimeout, remaining)
+		readTimeout = min(readTimeout, remaining)
+	}
+
+	return &util.RuntimeOptions{
+		ConnectTimeout: tea.Int(max(int(connectTimeout.Milliseconds()), 1)),
+		ReadTimeout:    tea.Int(max(int(readTimeout.Milliseconds()), 1)),
+	}, nil
+}
+
+// runtimeContexts 记录 callWithContext 生成的 RuntimeOptions 对应的 context，
+// 使 SDK 方法内部的装饰器（例如限流）可以感知调用方已经取消
+var runtimeContexts sync.Map
+
+// runtimeContext 返回 runtime 所属调用的 context，未登记时返回 context.Background()
+func runtimeContext(runtime *util.RuntimeOptions) context.Context {
+	if runtime != nil {
+		if ctx, ok := runtimeContexts.Load(runtime); ok {
+			return ctx.(context.Context)
+		}
+	}
+	return context.Background()
+}
+
+// callWithContext 使用 ctx 派生的超时执行一次 SDK 调用。
+// SDK 本身不支持 context，ctx 被取消时立即返回 ctx.Err()，
+// 后台的请求会在上面设置的超时内自行结束；
+// 还在限流器中排队的请求会通过 runtimeContext 得知取消，不再发出
+func callWithContext[T any](ctx context.Context, call func(runtime *util.RuntimeOptions) (T, error)) (T, error) {
+	var zero T
+	runtime, err := runtimeOptions(ctx)
+	if err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		response T
+		err      error
+	}
+	done := make(chan result, 1)
+	runtimeContexts.Store(runtime, ctx)
+	go func() {
+		defer runtimeContexts.Delete(runtime)
+		response, err := call(runtime)
+		done <- result{response, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeOptions(t *testing.T) {
+	// 没有截止时间时使用默认超时
+	runtime, err := runtimeOptions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int(defaultConnectTimeout.Milliseconds()), *runtime.ConnectTimeout)
+	assert.Equal(t, int(defaultReadTimeout.Milliseconds()), *runtime.ReadTimeout)
+
+	// 截止时间早于默认超时时，两个超时都不超过剩余时间
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	runtime, err = runtimeOptions(ctx)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, *runtime.ConnectTimeout, 2000)
+	assert.LessOrEqual(t, *runtime.ReadTimeout, 2000)
+	assert.Positive(t, *runtime.ReadTimeout)
+
+	// 截止时间晚于默认超时时仍使用默认值
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	runtime, err = runtimeOptions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int(defaultReadTimeout.Milliseconds()), *runtime.ReadTimeout)
+
+	// 已经取消的 context 不再发起调用
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = runtimeOptions(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCallWithContext_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := callWithContext(ctx, func(runtime *util.RuntimeOptions) (string, error) {
+		close(started)
+		// 模拟一个迟迟不返回的 API 调用
+		<-release
+		return "late", nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCallWithContext_Result(t *testing.T) {
+	response, err := callWithContext(context.Background(), func(runtime *util.RuntimeOptions) (string, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", response)
+
+	_, err = callWithContext(context.Background(), func(runtime *util.RuntimeOptions) (string, error) {
+		return "", errors.New("api error")
+	})
+	assert.EqualError(t, err, "api error")
+}
+
+func TestSolver_Present_Stopped(t *testing.T) {
+	// webhook 退出后 Present 不再调用 AliDNS
+	calls := 0
+	mockClient := &MockAliDNSClient{
+		DescribeDomainsFunc: func(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+			calls++
+			return nil, errors.New("unexpected call")
+		},
+	}
+
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	solver := NewSolver(newDNSProviderWithClient(mockClient))
+	solver.ctx = stopped
+
+	err := solver.Present(&v1alpha1.ChallengeRequest{
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key",
+		AllowAmbientCredentials: true,
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, calls)
+}
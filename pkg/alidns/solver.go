@@ -1,13 +1,22 @@
 package alidns
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"log/slog"
 
+	credential "github.com/aliyun/credentials-go/credentials"
 	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	"golang.org/x/net/idna"
+	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/cert-manager/cert-manager/pkg/issuer/acme/dns/util"
@@ -19,19 +28,51 @@ import (
 // interface.
 // 实现 cert-manager webhook Solver interface
 type Solver struct {
-	// If a Kubernetes 'clientset' is needed, you must:
-	// 1. uncomment the additional `dnsProvider` field in this structure below
-	// 2. uncomment the "k8s.io/dnsProvider-go/kubernetes" import at the top of the file
-	// 3. uncomment the relevant code in the Initialize method below
-	// 4. ensure your webhook's service account has the required RBAC role
-	//    assigned to it for interacting with the Kubernetes APIs you need.
-	//dnsProvider kubernetes.Clientset
+	// kubeClient 用于读取 Issuer 引用的 Secret，在 Initialize 中创建
+	kubeClient kubernetes.Interface
+	// dnsProvider 使用 webhook 自身（默认凭据链）的凭据
 	dnsProvider DNSProvider
+	// newDNSProvider 根据 Issuer 配置的凭据创建 DNSProvider，测试中可替换
+	newDNSProvider func(cred credential.Credential, regionID string) (DNSProvider, error)
+	// roleProviders 缓存 Secret 凭据和扮演 RAM 角色得到的 DNSProvider，直到 STS Token 过期
+	roleProviders providerCache
+	// stsURL 是 AssumeRoleWithOIDC 使用的 STS 地址，为空时由 getSTSURL 决定
+	stsURL string
+	// namespace 是 webhook 所在的 namespace，路由表引用的 Secret 从这里读取
+	namespace string
+	// routes 是按 zone 选择凭据的路由表，从 ConfigMap 加载
+	routes routeTable
+	// ctx 在 Initialize 收到 stopCh 后取消，用于中断进行中的 API 调用
+	ctx context.Context
+	// limiter 限制所有 DNSProvider 发往 AliDNS 的请求速率和并发数，为 nil 时不限制
+	limiter *rateLimiter
+	// recordLocks 串行化同一条记录（domain、rr、value）上并发的 Present 和 CleanUp
+	recordLocks keyedMutex
+	// leases 在多个副本之间串行化同一记录名上的修改，为 nil 时只使用 recordLocks
+	leases *leaseLocker
+	// journal 记录 Present 创建的记录 ID，供 CleanUp 直接删除
+	journal *challengeJournal
+	// cleanupQueue 保存 CleanUp 失败的记录，由后台任务重试删除
+	cleanupQueue *cleanupQueue
+	// ownership 决定写入记录备注的所有者标记，以及按 key 删除时是否校验所有者
+	ownership ownershipConfig
+	// propagation 决定 Present 是否等待记录在权威 DNS 服务器上生效
+	propagation propagationConfig
+	// lookupTXT 向权威 DNS 服务器查询 TXT 记录，为 nil 时使用 queryTXT，测试中可替换
+	lookupTXT func(ctx context.Context, fqdn, nameserver string) ([]string, error)
+	// defaultTTL 是 Issuer 和路由表都未配置 ttl 时使用的 TTL，为 0 时使用域名允许的最小 TTL
+	defaultTTL int
+	// minTTLs 缓存各域名允许的最小 TTL，在所有 AliDNS 的 DNSProvider 之间共享，
+	// 域名版本与访问它使用的凭据无关，重建 DNSProvider 后仍然有效
+	minTTLs minTTLCache
 }
 
 func NewSolver(dnsProvider DNSProvider) *Solver {
 	return &Solver{
-		dnsProvider: dnsProvider,
+		dnsProvider:  dnsProvider,
+		journal:      &challengeJournal{},
+		cleanupQueue: &cleanupQueue{},
+		ownership:    ownershipConfig{owner: defaultOwnerID},
 	}
 }
 
@@ -51,13 +92,51 @@ func NewSolver(dnsProvider DNSProvider) *Solver {
 // resource and fetch these credentials using a Kubernetes clientset.
 
 type Config struct {
-	// Change the two fields below according to the format of the configuration
-	// to be decoded.
 	// These fields will be set by users in the
 	// `issuer.spec.acme.dns01.providers.webhook.config` field.
+	// 所有 Secret 都从 ChallengeRequest.ResourceNamespace 中读取，
+	// 未配置时使用 webhook 自身的凭据。
 
-	//Email           string `json:"email"`
-	//APIKeySecretRef v1alpha1.SecretKeySelector `json:"apiKeySecretRef"`
+	// AccessKeyIDSecretRef 引用保存 AccessKey ID 的 Secret
+	AccessKeyIDSecretRef *cmmeta.SecretKeySelector `json:"accessKeyIdSecretRef,omitempty"`
+	// AccessKeySecretSecretRef 引用保存 AccessKey Secret 的 Secret
+	AccessKeySecretSecretRef *cmmeta.SecretKeySelector `json:"accessKeySecretSecretRef,omitempty"`
+	// SecurityTokenSecretRef 可选，引用 STS 临时凭证的 SecurityToken
+	SecurityTokenSecretRef *cmmeta.SecretKeySelector `json:"securityTokenSecretRef,omitempty"`
+
+	// RoleArn 设置后，在上述凭据（未配置时为 webhook 自身凭据）基础上扮演该 RAM 角色
+	RoleArn string `json:"roleArn,omitempty"`
+	// RoleSessionName 可选，扮演角色时使用的会话名称
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// ExternalId 可选，跨账号扮演角色时的外部 ID
+	ExternalId string `json:"externalId,omitempty"`
+	// SessionDuration 可选，STS Token 有效期（秒），默认 3600
+	SessionDuration int `json:"sessionDuration,omitempty"`
+
+	// ServiceAccountName 设置后使用 Issuer 所在 namespace 中该 ServiceAccount 的
+	// RRSA OIDC Token 扮演 RoleArn，不能与 Secret 凭据同时使用
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// OIDCProviderArn 可选，ACK 集群的 OIDC 提供商 ARN，
+	// 默认读取环境变量 ALIBABA_CLOUD_OIDC_PROVIDER_ARN
+	OIDCProviderArn string `json:"oidcProviderArn,omitempty"`
+
+	// RegionID 可选，覆盖环境变量 ALIBABA_CLOUD_REGION_ID 决定的 AliDNS endpoint
+	RegionID string `json:"regionId,omitempty"`
+
+	// Backend 可选，alidns（默认，公网权威解析）、pvtz（云解析 PrivateZone）
+	// 或 mirror（同时写入公网 AliDNS 和同名 PrivateZone）
+	Backend string `json:"backend,omitempty"`
+	// VpcID 可选，用于 pvtz 和 mirror，只使用绑定了该 VPC 的 zone
+	VpcID string `json:"vpcId,omitempty"`
+	// MirrorPolicy 可选，mirror 的失败策略：all（默认）要求两个 backend 都成功，
+	// public 只要求公网 AliDNS 成功
+	MirrorPolicy string `json:"mirrorPolicy,omitempty"`
+
+	// TTL 可选，challenge 记录的 TTL（秒），默认使用 webhook 的默认值，
+	// 未配置默认值时使用域名版本允许的最小 TTL
+	TTL int `json:"ttl,omitempty"`
+	// Lines 可选，在这些解析线路上分别添加 challenge 记录，默认只添加到默认线路
+	Lines []string `json:"lines,omitempty"`
 }
 
 // Name is used as the name for this DNS solver when referencing it on the ACME
@@ -76,66 +155,174 @@ func (s *Solver) Name() string {
 // This method should tolerate being called multiple times with the same value.
 // cert-manager itself will later perform a self check to ensure that the
 // solver has correctly configured the DNS provider.
-func (s *Solver) Present(ch *v1alpha1.ChallengeRequest) error {
-	if s.dnsProvider == nil {
-		return fmt.Errorf("alidns client not initialized")
+// 返回的错误会显示在 Challenge 的 status 中，阿里云 API 的错误附带处理建议
+func (s *Solver) Present(ch *v1alpha1.ChallengeRequest) (err error) {
+	start := time.Now()
+	defer func() {
+		err = actionableError(err)
+		observeOperation("present", util.UnFqdn(ch.ResolvedZone), start, err)
+	}()
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+
+	// 根据 cert-manager 解析的 zone 选择凭据
+	zone, _ := s.extractDomainAndRR(ch.ResolvedFQDN, ch.ResolvedZone)
+	provider, err := s.resolveProvider(ctx, ch, zone)
+	if err != nil {
+		return err
 	}
 
-	// not required in this solver
-	// cfg, err := loadConfig(ch.Config)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to load config: %w", err)
-	// }
+	opts, err := s.recordOptions(ch, zone)
+	if err != nil {
+		return err
+	}
 
-	// 解析域名和记录名
-	domain, rr := s.extractDomainAndRR(ch.ResolvedFQDN, ch.ResolvedZone)
+	// 解析账号中实际添加的域名和记录名
+	domain, rr, err := provider.ResolveDomain(ctx, ch.ResolvedFQDN)
+	if err != nil {
+		return err
+	}
 
-	// 添加 TXT 记录
-	recordId, err := s.dnsProvider.AddTXTRecord(domain, rr, ch.Key)
+	recordIds, err := s.addRecords(ctx, provider, ch, domain, rr, opts)
 	if err != nil {
-		return fmt.Errorf("failed to add TXT record: %w", err)
+		return err
 	}
 
 	slog.Info("Successfully added TXT record",
 		"domain", domain,
 		"rr", rr,
 		"value", ch.Key,
-		"recordId", recordId,
+		"recordIds", recordIds,
 	)
 
+	// 等待记录在权威 DNS 服务器上生效（可选），此时已释放记录锁
+	if s.propagation.enabled {
+		s.waitForPropagation(ctx, provider, domain, rr, ch.Key)
+	}
 	return nil
 }
 
+// addRecords 在记录锁内为每个线路添加 TXT 记录、标记所有者并写入 journal
+func (s *Solver) addRecords(ctx context.Context, provider DNSProvider, ch *v1alpha1.ChallengeRequest, domain, rr string, opts []RecordOptions) ([]string, error) {
+	unlock, err := s.lockRecord(ctx, domain, rr, ch.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// 在备注中标记记录的所有者，严格模式下 CleanUp 只按 key 删除带有本实例标记的记录
+	marker := recordMarker{
+		Owner:   s.ownership.owner,
+		Name:    util.UnFqdn(ch.ResolvedFQDN),
+		Created: time.Now(),
+	}
+	snapshot := newChallengeSnapshot(ch)
+	entry := journalEntry{
+		Domain:    domain,
+		RR:        rr,
+		Value:     ch.Key,
+		Challenge: &snapshot,
+	}
+	for _, o := range opts {
+		// 添加 TXT 记录
+		recordId, err := provider.AddTXTRecord(ctx, domain, rr, ch.Key, o)
+		if err != nil {
+			if o.Line != "" {
+				return nil, fmt.Errorf("failed to add TXT record on line %s: %w", o.Line, err)
+			}
+			return nil, fmt.Errorf("failed to add TXT record: %w", err)
+		}
+		// 每条记录创建后立即写入 journal，后面的线路失败时 CleanUp 仍能按 ID 删除已创建的记录
+		entry.RecordIDs = append(entry.RecordIDs, recordId)
+		s.journal.put(ctx, journalKey(ch), entry)
+
+		if err := provider.SetRecordRemark(ctx, recordId, marker.String()); err != nil {
+			if s.ownership.strict {
+				return nil, fmt.Errorf("failed to tag TXT record: %w", err)
+			}
+			slog.Warn("Failed to tag TXT record with owner", "recordId", recordId, "error", err)
+		}
+	}
+	return entry.RecordIDs, nil
+}
+
 // CleanUp should delete the relevant TXT record from the DNS provider console.
 // If multiple TXT records exist with the same record name (e.g.
 // _acme-challenge.example.com) then **only** the record with the same `key`
 // value provided on the ChallengeRequest should be cleaned up.
 // This is in order to facilitate multiple DNS validations for the same domain
 // concurrently.
-func (s *Solver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
-	if s.dnsProvider == nil {
-		return fmt.Errorf("alidns client not initialized")
+func (s *Solver) CleanUp(ch *v1alpha1.ChallengeRequest) (err error) {
+	start := time.Now()
+	defer func() { observeOperation("cleanup", util.UnFqdn(ch.ResolvedZone), start, err) }()
+
+	ctx, cancel := s.operationContext()
+	defer cancel()
+
+	// 删除失败时加入重试队列（配置、权限等永久错误除外），即使 cert-manager 不再调用 CleanUp 也会在后台删除
+	if err := s.cleanUp(ctx, ch); err != nil {
+		s.cleanupQueue.add(ch, err)
+		return err
 	}
+	s.cleanupQueue.remove(ch)
+	return nil
+}
 
-	// not required in this solver
-	// cfg, err := loadConfig(ch.Config)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to load config: %w", err)
-	// }
+// cleanUp 删除 challenge 对应的 TXT 记录，CleanUp 和后台重试共用
+func (s *Solver) cleanUp(ctx context.Context, ch *v1alpha1.ChallengeRequest) error {
+	// 根据 cert-manager 解析的 zone 选择凭据
+	zone, _ := s.extractDomainAndRR(ch.ResolvedFQDN, ch.ResolvedZone)
+	provider, err := s.resolveProvider(ctx, ch, zone)
+	if err != nil {
+		return err
+	}
 
-	// 解析域名和记录名
-	domain, rr := s.extractDomainAndRR(ch.ResolvedFQDN, ch.ResolvedZone)
+	// Present 记录过的 challenge 直接按记录 ID 删除，否则解析域名后按 key 查找
+	var domain, rr string
+	var recordIds []string
+	if entry, ok := s.journal.get(ctx, journalKey(ch)); ok && entry.Value == ch.Key {
+		domain, rr, recordIds = entry.Domain, entry.RR, entry.RecordIDs
+	} else {
+		// 解析账号中实际添加的域名和记录名
+		domain, rr, err = provider.ResolveDomain(ctx, ch.ResolvedFQDN)
+		if err != nil {
+			return err
+		}
+	}
 
-	// 删除记录（根据 key 值匹配）
-	err := s.dnsProvider.DeleteRecordsByKey(domain, rr, ch.Key)
+	unlock, err := s.lockRecord(ctx, domain, rr, ch.Key)
 	if err != nil {
-		return fmt.Errorf("failed to delete TXT record: %w", err)
+		return err
 	}
+	defer unlock()
+
+	for _, recordId := range recordIds {
+		if err := provider.DeleteRecord(ctx, recordId); err != nil {
+			slog.Warn("Failed to delete TXT record by ID, searching by key instead",
+				"domain", domain,
+				"rr", rr,
+				"recordId", recordId,
+				"error", err,
+			)
+			recordIds = nil
+			break
+		}
+	}
+	if len(recordIds) == 0 {
+		// 删除所有线路上的记录（根据 key 值匹配）
+		err = provider.DeleteRecordsByKey(ctx, domain, rr, ch.Key, s.ownership.deleteOwner())
+		if err != nil {
+			return fmt.Errorf("failed to delete TXT record: %w", err)
+		}
+	}
+	s.journal.remove(ctx, journalKey(ch))
 
 	slog.Info("Successfully deleted TXT record",
 		"domain", domain,
 		"rr", rr,
 		"value", ch.Key,
+		"recordIds", recordIds,
 	)
 	return nil
 }
@@ -150,36 +337,215 @@ func (s *Solver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
 // The stopCh can be used to handle early termination of the webhook, in cases
 // where a SIGTERM or similar signal is sent to the webhook process.
 func (s *Solver) Initialize(kubeClientConfig *rest.Config, stopCh <-chan struct{}) error {
-	// 如果需要 Kubernetes 客户端（例如从 Secret 读取配置），可以在这里初始化
-	// if kubeClientConfig != nil {
-	// 	cl, err := kubernetes.NewForConfig(kubeClientConfig)
-	// 	if err != nil {
-	// 		return fmt.Errorf("failed to create kubernetes client: %w", err)
-	// 	}
-	// 	_ = cl // 避免未使用变量警告
-	// }
-	client, err := NewDNSProvider()
+	// webhook 退出时取消进行中的 API 调用
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	s.ctx = ctx
+
+	// Kubernetes 客户端用于从 Secret 读取 Issuer 配置的凭据
+	if kubeClientConfig != nil {
+		cl, err := kubernetes.NewForConfig(kubeClientConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		s.kubeClient = cl
+	}
+
+	// 按 zone 选择凭据的路由表（可选）
+	s.namespace = os.Getenv("POD_NAMESPACE")
+	if name := os.Getenv("ALIDNS_ROUTES_CONFIGMAP"); name != "" {
+		if s.kubeClient == nil || s.namespace == "" {
+			return fmt.Errorf("ALIDNS_ROUTES_CONFIGMAP requires a kubernetes client and POD_NAMESPACE")
+		}
+		s.watchRoutes(s.namespace, name, stopCh)
+	}
+
+	// challenge journal 默认只保存在内存中，可选持久化到 ConfigMap
+	s.journal = &challengeJournal{}
+	if name := os.Getenv("ALIDNS_JOURNAL_CONFIGMAP"); name != "" {
+		if s.kubeClient == nil || s.namespace == "" {
+			return fmt.Errorf("ALIDNS_JOURNAL_CONFIGMAP requires a kubernetes client and POD_NAMESPACE")
+		}
+		s.journal = newConfigMapJournal(s.kubeClient, s.namespace, name)
+	}
+
+	// CleanUp 失败的记录在后台重试删除，可选持久化到 ConfigMap
+	s.cleanupQueue = &cleanupQueue{}
+	if name := os.Getenv("ALIDNS_CLEANUP_QUEUE_CONFIGMAP"); name != "" {
+		if s.kubeClient == nil || s.namespace == "" {
+			return fmt.Errorf("ALIDNS_CLEANUP_QUEUE_CONFIGMAP requires a kubernetes client and POD_NAMESPACE")
+		}
+		s.cleanupQueue = newConfigMapCleanupQueue(s.kubeClient, s.namespace, name)
+		if err := s.cleanupQueue.load(ctx); err != nil {
+			return fmt.Errorf("failed to load cleanup queue: %w", err)
+		}
+	}
+	registerMetrics()
+
+	// 记录所有权标记
+	ownership, err := ownershipConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	s.ownership = ownership
+
+	// challenge 记录的默认 TTL（可选）
+	if s.defaultTTL, err = intEnv("ALIDNS_DEFAULT_TTL"); err != nil {
+		return err
+	}
+
+	// Present 返回前等待权威 DNS 服务器生效（可选）
+	propagation, err := propagationConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	s.propagation = propagation
+
+	// 多副本之间通过 Lease 协调（可选）
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+	if os.Getenv("ALIDNS_LEASE_COORDINATION") == "true" {
+		if s.kubeClient == nil || s.namespace == "" {
+			return fmt.Errorf("ALIDNS_LEASE_COORDINATION requires a kubernetes client and POD_NAMESPACE")
+		}
+		s.leases = newLeaseLocker(s.kubeClient, s.namespace, identity)
+	}
+
+	// AliDNS API 限流（可选）
+	limits, err := rateLimitConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	s.limiter = newRateLimiter(limits)
+
+	cred, err := credential.NewCredential(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create alidns client: %w", err)
+	}
+	client, err := newAliDNSClient(cred, "")
 	if err != nil {
 		return fmt.Errorf("failed to create alidns client: %w", err)
 	}
-	s.dnsProvider = client
+	s.dnsProvider = s.newAliDNSProvider(s.limiter.wrap(client, defaultAccount))
+
+	// Challenge 在 CleanUp 之前被删除时清理其记录（可选）
+	if os.Getenv("ALIDNS_CHALLENGE_WATCHER") == "true" {
+		if kubeClientConfig == nil {
+			return fmt.Errorf("ALIDNS_CHALLENGE_WATCHER requires a kubernetes client")
+		}
+		dyn, err := dynamic.NewForConfig(kubeClientConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create dynamic kubernetes client: %w", err)
+		}
+		s.watchChallenges(dyn, stopCh)
+	}
+
+	// 定期回收孤儿 challenge 记录（可选），多副本时通过 Lease 选主
+	gc, err := gcConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if len(gc.zones) > 0 {
+		slog.Info("Starting record garbage collection", "zones", gc.zones, "maxAge", gc.maxAge, "dryRun", gc.dryRun)
+		go s.runGC(gc, identity, stopCh)
+	}
+
+	// 重试队列最后启动，此时 ownership、limiter、dnsProvider 等字段都已赋值
+	go s.runCleanupQueue(stopCh)
 	return nil
 }
 
+// lockRecord 先获取进程内的记录锁，启用 Lease 协调时再获取 (domain, rr) 的 Lease
+func (s *Solver) lockRecord(ctx context.Context, domain, rr, value string) (func(), error) {
+	unlock, err := s.recordLocks.lock(ctx, recordLockKey(domain, rr, value))
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.leases.lock(ctx, domain, rr)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return func() {
+		release()
+		unlock()
+	}, nil
+}
+
+// operationContext 返回一次 Present / CleanUp 使用的 context，
+// 在 operationTimeout 后或 webhook 退出时取消
+func (s *Solver) operationContext() (context.Context, context.CancelFunc) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, operationTimeout)
+}
+
+// recordOptions 返回添加记录时使用的参数，每个解析线路一个。
+// Issuer 的配置优先，其次是匹配 zone 的路由表条目，最后是 webhook 的默认值
+func (s *Solver) recordOptions(ch *v1alpha1.ChallengeRequest, zone string) ([]RecordOptions, error) {
+	cfg, err := loadConfig(ch.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	// 只有路由提供了凭据时才继承路由的 ttl 和 lines
+	route := s.routeFor(cfg, zone)
+
+	ttl := cfg.TTL
+	if ttl == 0 && route != nil {
+		ttl = route.TTL
+	}
+	if ttl == 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("invalid ttl %d: must not be negative", ttl)
+	}
+
+	lines := cfg.Lines
+	if len(lines) == 0 && route != nil {
+		lines = route.Lines
+	}
+	if len(lines) == 0 {
+		return []RecordOptions{{TTL: ttl}}, nil
+	}
+	opts := make([]RecordOptions, 0, len(lines))
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if line == "" {
+			return nil, fmt.Errorf("invalid lines: line must not be empty")
+		}
+		if !seen[line] {
+			seen[line] = true
+			opts = append(opts, RecordOptions{TTL: ttl, Line: line})
+		}
+	}
+	return opts, nil
+}
+
 // loadConfig is a small helper function that decodes JSON configuration into
 // the typed config struct.
-// func loadConfig(cfgJSON *extapi.JSON) (*Config, error) {
-// 	cfg := &Config{}
-// 	if cfgJSON == nil {
-// 		return cfg, nil
-// 	}
-
-// 	if err := json.Unmarshal(cfgJSON.Raw, cfg); err != nil {
-// 		return nil, fmt.Errorf("error decoding solver config: %w", err)
-// 	}
-
-// 	return cfg, nil
-// }
+func loadConfig(cfgJSON *extapi.JSON) (*Config, error) {
+	cfg := &Config{}
+	if cfgJSON == nil {
+		return cfg, nil
+	}
+
+	if err := json.Unmarshal(cfgJSON.Raw, cfg); err != nil {
+		return nil, fmt.Errorf("error decoding solver config: %w", err)
+	}
+	if err := cfg.validateBackend(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
 
 // extractDomainAndRR 从 FQDN 和 Zone 中提取域名和记录名
 // 例如：
@@ -1,33 +1,69 @@
 package alidns
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 )
 
 // MockDNSProvider is a mock implementation of DNSProvider
 type MockDNSProvider struct {
+	ResolveDomainFunc      func(fqdn string) (string, string, error)
 	AddTXTRecordFunc       func(domain, rr, value string) (string, error)
+	DeleteRecordFunc       func(recordId string) error
 	DeleteRecordsByKeyFunc func(domain, rr, value string) error
+	SetRecordRemarkFunc    func(recordId, remark string) error
+
+	// AddedOptions 记录每次 AddTXTRecord 传入的参数
+	AddedOptions []RecordOptions
 }
 
-func (m *MockDNSProvider) AddTXTRecord(domain, rr, value string) (string, error) {
+// ResolveDomain 默认把 FQDN 的最后两级作为域名
+func (m *MockDNSProvider) ResolveDomain(ctx context.Context, fqdn string) (string, string, error) {
+	if m.ResolveDomainFunc != nil {
+		return m.ResolveDomainFunc(fqdn)
+	}
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	if len(labels) <= 2 {
+		return strings.Join(labels, "."), "@", nil
+	}
+	return strings.Join(labels[len(labels)-2:], "."), strings.Join(labels[:len(labels)-2], "."), nil
+}
+
+func (m *MockDNSProvider) AddTXTRecord(ctx context.Context, domain, rr, value string, opts RecordOptions) (string, error) {
+	m.AddedOptions = append(m.AddedOptions, opts)
 	if m.AddTXTRecordFunc != nil {
 		return m.AddTXTRecordFunc(domain, rr, value)
 	}
 	return "mock-record-id", nil
 }
 
-func (m *MockDNSProvider) DeleteRecordsByKey(domain, rr, value string) error {
+func (m *MockDNSProvider) DeleteRecord(ctx context.Context, recordId string) error {
+	if m.DeleteRecordFunc != nil {
+		return m.DeleteRecordFunc(recordId)
+	}
+	return nil
+}
+
+func (m *MockDNSProvider) DeleteRecordsByKey(ctx context.Context, domain, rr, value, owner string) error {
 	if m.DeleteRecordsByKeyFunc != nil {
 		return m.DeleteRecordsByKeyFunc(domain, rr, value)
 	}
 	return nil
 }
 
+func (m *MockDNSProvider) SetRecordRemark(ctx context.Context, recordId, remark string) error {
+	if m.SetRecordRemarkFunc != nil {
+		return m.SetRecordRemarkFunc(recordId, remark)
+	}
+	return nil
+}
+
 func TestSolver_Name(t *testing.T) {
 	solver := &Solver{}
 	assert.Equal(t, "alidns", solver.Name(), "Expected solver name to be 'alidns'")
@@ -124,9 +160,10 @@ func TestSolver_Present(t *testing.T) {
 	}
 
 	ch := &v1alpha1.ChallengeRequest{
-		ResolvedFQDN: "_acme-challenge.example.com.",
-		ResolvedZone: "example.com.",
-		Key:          "test-key-value",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
 	}
 
 	err := solver.Present(ch)
@@ -145,9 +182,10 @@ func TestSolver_Present_Error(t *testing.T) {
 	}
 
 	ch := &v1alpha1.ChallengeRequest{
-		ResolvedFQDN: "_acme-challenge.example.com.",
-		ResolvedZone: "example.com.",
-		Key:          "test-key-value",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
 	}
 
 	err := solver.Present(ch)
@@ -170,9 +208,10 @@ func TestSolver_CleanUp(t *testing.T) {
 	}
 
 	ch := &v1alpha1.ChallengeRequest{
-		ResolvedFQDN: "_acme-challenge.example.com.",
-		ResolvedZone: "example.com.",
-		Key:          "test-key-value",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
 	}
 
 	err := solver.CleanUp(ch)
@@ -191,9 +230,10 @@ func TestSolver_CleanUp_Error(t *testing.T) {
 	}
 
 	ch := &v1alpha1.ChallengeRequest{
-		ResolvedFQDN: "_acme-challenge.example.com.",
-		ResolvedZone: "example.com.",
-		Key:          "test-key-value",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
 	}
 
 	err := solver.CleanUp(ch)
@@ -201,9 +241,106 @@ func TestSolver_CleanUp_Error(t *testing.T) {
 	assert.Contains(t, err.Error(), "mock delete error")
 }
 
+func TestSolver_Lines(t *testing.T) {
+	ids := 0
+	var deleted []string
+	mockProvider := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			ids++
+			return fmt.Sprintf("record-%d", ids), nil
+		},
+		DeleteRecordFunc: func(recordId string) error {
+			deleted = append(deleted, recordId)
+			return nil
+		},
+		DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+			t.Fatal("records should be deleted by ID")
+			return nil
+		},
+	}
+	solver := NewSolver(mockProvider)
+
+	ch := &v1alpha1.ChallengeRequest{
+		UID:                     "challenge-1",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+		Config:                  &extapi.JSON{Raw: []byte(`{"lines":["default","telecom","overseas","telecom"]}`)},
+	}
+
+	assert.NoError(t, solver.Present(ch))
+	assert.Equal(t, []RecordOptions{{Line: "default"}, {Line: "telecom"}, {Line: "overseas"}}, mockProvider.AddedOptions)
+
+	assert.NoError(t, solver.CleanUp(ch))
+	assert.Equal(t, []string{"record-1", "record-2", "record-3"}, deleted)
+}
+
+func TestSolver_Lines_FallbackByKey(t *testing.T) {
+	// 任一线路的记录按 ID 删除失败时，按 key 删除所有线路上的记录
+	byKey := 0
+	mockProvider := &MockDNSProvider{
+		DeleteRecordFunc: func(recordId string) error {
+			return fmt.Errorf("mock delete error")
+		},
+		DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+			byKey++
+			return nil
+		},
+	}
+	solver := NewSolver(mockProvider)
+
+	ch := &v1alpha1.ChallengeRequest{
+		UID:                     "challenge-1",
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+		Config:                  &extapi.JSON{Raw: []byte(`{"lines":["telecom","unicom"]}`)},
+	}
+
+	assert.NoError(t, solver.Present(ch))
+	assert.NoError(t, solver.CleanUp(ch))
+	assert.Equal(t, 1, byKey)
+}
+
+func TestSolver_Lines_PartialFailure(t *testing.T) {
+	// 后面的线路添加失败时，已创建的记录已写入 journal，CleanUp 按 ID 删除
+	var deleted []string
+	mockProvider := &MockDNSProvider{
+		DeleteRecordFunc: func(recordId string) error {
+			deleted = append(deleted, recordId)
+			return nil
+		},
+		DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+			t.Fatal("records should be deleted by ID")
+			return nil
+		},
+	}
+	mockProvider.AddTXTRecordFunc = func(domain, rr, value string) (string, error) {
+		if len(mockProvider.AddedOptions) > 1 {
+			return "", fmt.Errorf("mock api error")
+		}
+		return "record-1", nil
+	}
+	solver := NewSolver(mockProvider)
+
+	ch := &v1alpha1.ChallengeRequest{
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+		Config:                  &extapi.JSON{Raw: []byte(`{"lines":["telecom","unicom"]}`)},
+	}
+
+	assert.ErrorContains(t, solver.Present(ch), "failed to add TXT record on line unicom")
+	assert.NoError(t, solver.CleanUp(ch))
+	assert.Equal(t, []string{"record-1"}, deleted)
+}
+
 func TestSolver_Present_Uninitialized(t *testing.T) {
 	solver := &Solver{dnsProvider: nil}
-	ch := &v1alpha1.ChallengeRequest{}
+	ch := &v1alpha1.ChallengeRequest{AllowAmbientCredentials: true}
 	err := solver.Present(ch)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not initialized")
@@ -211,12 +348,113 @@ func TestSolver_Present_Uninitialized(t *testing.T) {
 
 func TestSolver_CleanUp_Uninitialized(t *testing.T) {
 	solver := &Solver{dnsProvider: nil}
-	ch := &v1alpha1.ChallengeRequest{}
+	ch := &v1alpha1.ChallengeRequest{AllowAmbientCredentials: true}
 	err := solver.CleanUp(ch)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not initialized")
 }
 
+func TestSolver_Present_DomainNotFound(t *testing.T) {
+	mockProvider := &MockDNSProvider{
+		ResolveDomainFunc: func(fqdn string) (string, string, error) {
+			return "", "", fmt.Errorf("no domain in this Alibaba Cloud DNS account covers %s", fqdn)
+		},
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			t.Fatal("AddTXTRecord must not be called")
+			return "", nil
+		},
+	}
+	solver := &Solver{dnsProvider: mockProvider}
+
+	ch := &v1alpha1.ChallengeRequest{
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+	}
+
+	err := solver.Present(ch)
+	assert.ErrorContains(t, err, "no domain in this Alibaba Cloud DNS account covers")
+}
+
+func TestSolver_Present_ActionableError(t *testing.T) {
+	mockProvider := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			return "", fmt.Errorf("failed to add domain record: %w", newAPIError("AddDomainRecord", newOpenAPIError("Forbidden.RAM", 403)))
+		},
+	}
+	solver := &Solver{dnsProvider: mockProvider}
+
+	ch := &v1alpha1.ChallengeRequest{
+		ResolvedFQDN:            "_acme-challenge.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+	}
+
+	err := solver.Present(ch)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	assert.ErrorContains(t, err, "request id req-1")
+	assert.ErrorContains(t, err, "grant AddDomainRecord to the RAM user or role used by the Issuer")
+}
+
+func TestSolver_Present_RegisteredSubdomain(t *testing.T) {
+	mockProvider := &MockDNSProvider{
+		ResolveDomainFunc: func(fqdn string) (string, string, error) {
+			assert.Equal(t, "_acme-challenge.www.dev.example.com.", fqdn)
+			return "dev.example.com", "_acme-challenge.www", nil
+		},
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			assert.Equal(t, "dev.example.com", domain)
+			assert.Equal(t, "_acme-challenge.www", rr)
+			return "12345", nil
+		},
+	}
+	solver := &Solver{dnsProvider: mockProvider}
+
+	// cert-manager 的 SOA 查询可能返回上一级 zone
+	ch := &v1alpha1.ChallengeRequest{
+		ResolvedFQDN:            "_acme-challenge.www.dev.example.com.",
+		ResolvedZone:            "example.com.",
+		Key:                     "test-key-value",
+		AllowAmbientCredentials: true,
+	}
+
+	assert.NoError(t, solver.Present(ch))
+}
+
+func TestSolver_AmbientCredentialsNotAllowed(t *testing.T) {
+	mockProvider := &MockDNSProvider{
+		AddTXTRecordFunc: func(domain, rr, value string) (string, error) {
+			t.Fatal("ambient provider must not be used")
+			return "", nil
+		},
+		DeleteRecordsByKeyFunc: func(domain, rr, value string) error {
+			t.Fatal("ambient provider must not be used")
+			return nil
+		},
+	}
+	solver := &Solver{dnsProvider: mockProvider}
+
+	for _, config := range []string{`{}`, `{"roleArn": "acs:ram::1234567890:role/dns-admin"}`} {
+		ch := &v1alpha1.ChallengeRequest{
+			ResolvedFQDN:      "_acme-challenge.example.com.",
+			ResolvedZone:      "example.com.",
+			Key:               "test-key-value",
+			ResourceNamespace: "team-a",
+			Config:            &extapi.JSON{Raw: []byte(config)},
+		}
+
+		err := solver.Present(ch)
+		assert.ErrorContains(t, err, "ambient credentials are not allowed")
+		assert.ErrorContains(t, err, "accessKeyIdSecretRef")
+		assert.ErrorContains(t, err, "serviceAccountName")
+
+		err = solver.CleanUp(ch)
+		assert.ErrorContains(t, err, "ambient credentials are not allowed")
+	}
+}
+
 func TestExtractDomainAndRR_Punycode(t *testing.T) {
 	solver := &Solver{}
 
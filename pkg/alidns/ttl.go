@@ -0,0 +1,93 @@
+package alidns
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+const (
+	// maxRecordTTL 是 AliDNS 允许的最大 TTL
+	maxRecordTTL = 86400
+	// minTTLCacheDuration 是域名最小 TTL 的缓存时间，域名升级版本后最晚在此时间后生效
+	minTTLCacheDuration = time.Hour
+)
+
+// minTTLCache 缓存各域名版本允许的最小 TTL，nil 时不缓存
+type minTTLCache struct {
+	mu      sync.Mutex
+	entries map[string]minTTLEntry
+}
+
+type minTTLEntry struct {
+	ttl       int64
+	fetchedAt time.Time
+}
+
+func (c *minTTLCache) get(domain string) (int64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[domain]
+	if !ok || time.Since(entry.fetchedAt) > minTTLCacheDuration {
+		return 0, false
+	}
+	return entry.ttl, true
+}
+
+func (c *minTTLCache) set(domain string, ttl int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]minTTLEntry{}
+	}
+	c.entries[domain] = minTTLEntry{ttl: ttl, fetchedAt: time.Now()}
+}
+
+// minTTL 返回 domain 的版本（免费版、企业版等）允许的最小 TTL，未知时返回 0
+func (p *dnsProvider) minTTL(ctx context.Context, domain string) (int64, error) {
+	if ttl, ok := p.minTTLs.get(domain); ok {
+		return ttl, nil
+	}
+	info, err := p.describeDomainInfo(ctx, domain)
+	if err != nil {
+		return 0, err
+	}
+	ttl := tea.Int64Value(info.MinTtl)
+	p.minTTLs.set(domain, ttl)
+	return ttl, nil
+}
+
+// recordTTL 返回添加记录时使用的 TTL，requested 为 0 时使用域名允许的最小 TTL。
+// 超出允许范围的 TTL 会被调整并输出警告；返回 false 表示不设置 TTL，使用账号默认值
+func (p *dnsProvider) recordTTL(ctx context.Context, domain string, requested int) (int64, bool) {
+	ttl := int64(requested)
+	minTTL, err := p.minTTL(ctx, domain)
+	if err != nil {
+		slog.Warn("Failed to detect minimum TTL for domain", "domain", domain, "error", err)
+	}
+
+	switch {
+	case ttl == 0 && minTTL == 0:
+		return 0, false
+	case ttl == 0:
+		return minTTL, true
+	case ttl < minTTL:
+		slog.Warn("TTL is below the minimum allowed for the domain, using the minimum",
+			"domain", domain, "ttl", ttl, "minTTL", minTTL)
+		return minTTL, true
+	case ttl > maxRecordTTL:
+		slog.Warn("TTL is above the maximum allowed, using the maximum",
+			"domain", domain, "ttl", ttl, "maxTTL", maxRecordTTL)
+		return maxRecordTTL, true
+	}
+	return ttl, true
+}
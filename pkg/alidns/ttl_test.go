@@ -0,0 +1,160 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// newDomainInfoFunc 返回报告 minTTL 的 DescribeDomainInfo，并统计调用次数
+func newDomainInfoFunc(minTTL *int64, err error, calls *int) func(*alidns.DescribeDomainInfoRequest, *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+	return func(request *alidns.DescribeDomainInfoRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainInfoResponse, error) {
+		*calls++
+		if err != nil {
+			return nil, err
+		}
+		return &alidns.DescribeDomainInfoResponse{
+			Body: &alidns.DescribeDomainInfoResponseBody{MinTtl: minTTL},
+		}, nil
+	}
+}
+
+func TestRecordTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		minTTL    *int64
+		infoErr   error
+		requested int
+		expectTTL int64
+		expectSet bool
+	}{
+		{name: "default uses minimum TTL", minTTL: tea.Int64(600), requested: 0, expectTTL: 600, expectSet: true},
+		{name: "enterprise minimum", minTTL: tea.Int64(1), requested: 0, expectTTL: 1, expectSet: true},
+		{name: "requested TTL", minTTL: tea.Int64(60), requested: 120, expectTTL: 120, expectSet: true},
+		{name: "clamped to minimum", minTTL: tea.Int64(600), requested: 60, expectTTL: 600, expectSet: true},
+		{name: "clamped to maximum", minTTL: tea.Int64(1), requested: 100000, expectTTL: maxRecordTTL, expectSet: true},
+		{name: "unknown minimum", minTTL: nil, requested: 0, expectSet: false},
+		{name: "lookup failure keeps requested TTL", infoErr: errors.New("forbidden"), requested: 60, expectTTL: 60, expectSet: true},
+		{name: "lookup failure uses account default", infoErr: errors.New("forbidden"), requested: 0, expectSet: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFastRetries(t, time.Second)
+			calls := 0
+			provider := newDNSProviderWithClient(&MockAliDNSClient{
+				DescribeDomainInfoFunc: newDomainInfoFunc(tt.minTTL, tt.infoErr, &calls),
+			})
+
+			ttl, ok := provider.recordTTL(context.Background(), "example.com", tt.requested)
+			assert.Equal(t, tt.expectSet, ok)
+			if tt.expectSet {
+				assert.Equal(t, tt.expectTTL, ttl)
+			}
+		})
+	}
+}
+
+func TestMinTTL_Cached(t *testing.T) {
+	calls := 0
+	provider := newDNSProviderWithClient(&MockAliDNSClient{
+		DescribeDomainInfoFunc: newDomainInfoFunc(tea.Int64(600), nil, &calls),
+	})
+
+	for range 3 {
+		ttl, err := provider.minTTL(context.Background(), "example.com")
+		require.NoError(t, err)
+		assert.Equal(t, int64(600), ttl)
+	}
+	assert.Equal(t, 1, calls)
+
+	_, err := provider.minTTL(context.Background(), "example.org")
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestSolver_MinTTLShared(t *testing.T) {
+	calls := 0
+	solver := NewSolver(&MockDNSProvider{})
+	client := &MockAliDNSClient{
+		DescribeDomainInfoFunc: newDomainInfoFunc(tea.Int64(600), nil, &calls),
+	}
+
+	// 重建的 DNSProvider（例如 STS Token 过期后）复用已查询到的最小 TTL
+	for range 3 {
+		ttl, err := solver.newAliDNSProvider(client).minTTL(context.Background(), "example.com")
+		require.NoError(t, err)
+		assert.Equal(t, int64(600), ttl)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestAddTXTRecord_TTL(t *testing.T) {
+	calls := 0
+	var requested *int64
+	provider := newDNSProviderWithClient(&MockAliDNSClient{
+		DescribeDomainInfoFunc: newDomainInfoFunc(tea.Int64(600), nil, &calls),
+		AddDomainRecordFunc: func(request *alidns.AddDomainRecordRequest, runtime *util.RuntimeOptions) (*alidns.AddDomainRecordResponse, error) {
+			requested = request.TTL
+			return &alidns.AddDomainRecordResponse{Body: &alidns.AddDomainRecordResponseBody{RecordId: tea.String("record-1")}}, nil
+		},
+	})
+
+	_, err := provider.AddTXTRecord(context.Background(), "example.com", "_acme-challenge", "test-value", RecordOptions{TTL: 60})
+	require.NoError(t, err)
+	require.NotNil(t, requested)
+	assert.Equal(t, int64(600), *requested)
+}
+
+func TestSolver_RecordOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		routeTTL    int
+		defaultTTL  int
+		expectTTL   int
+		expectError bool
+	}{
+		{name: "nothing configured", config: `{}`, expectTTL: 0},
+		{name: "webhook default", config: `{}`, defaultTTL: 300, expectTTL: 300},
+		{name: "route overrides default", config: `{}`, routeTTL: 120, defaultTTL: 300, expectTTL: 120},
+		{name: "issuer overrides route", config: `{"ttl":60}`, routeTTL: 120, defaultTTL: 300, expectTTL: 60},
+		{
+			name:       "issuer credentials skip route",
+			config:     `{"accessKeyIdSecretRef":{"name":"alidns","key":"id"},"accessKeySecretSecretRef":{"name":"alidns","key":"secret"}}`,
+			routeTTL:   120,
+			defaultTTL: 300,
+			expectTTL:  300,
+		},
+		{name: "negative ttl", config: `{"ttl":-1}`, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solver := NewSolver(nil)
+			solver.defaultTTL = tt.defaultTTL
+			if tt.routeTTL != 0 {
+				solver.routes.set([]ZoneRoute{{Zone: "example.com", Config: Config{TTL: tt.routeTTL}}})
+			}
+			ch := &v1alpha1.ChallengeRequest{Config: &extapi.JSON{Raw: []byte(tt.config)}}
+
+			opts, err := solver.recordOptions(ch, "example.com")
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, opts, 1)
+			assert.Equal(t, tt.expectTTL, opts[0].TTL)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package alidns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+const (
+	// zoneCacheTTL 是账号下域名列表的缓存时间
+	zoneCacheTTL = 5 * time.Minute
+	// zoneMissRefreshInterval 是未匹配到域名时强制刷新缓存的最小间隔，
+	// 避免新添加的域名要等到缓存过期才能使用，同时防止频繁调用 DescribeDomains
+	zoneMissRefreshInterval = 30 * time.Second
+)
+
+// zoneResolver 通过 DescribeDomains 查询账号下已添加到云解析的域名，
+// 并为 FQDN 找到最长匹配的域名
+type zoneResolver struct {
+	client AliDNSClient
+
+	mu        sync.Mutex
+	domains   []string
+	fetchedAt time.Time
+	// now 用于测试中替换时钟
+	now func() time.Time
+}
+
+// ResolveDomain 返回覆盖 fqdn 的最长已注册域名以及对应的主机记录（RR）。
+// 例如 dev.example.com 单独添加到云解析时，
+// _acme-challenge.www.dev.example.com 解析为 dev.example.com 和 _acme-challenge.www
+func (z *zoneResolver) ResolveDomain(ctx context.Context, fqdn string) (string, string, error) {
+	name := normalizeZone(fqdn)
+
+	domains, err := z.list(ctx, false)
+	if err != nil {
+		return "", "", err
+	}
+	if domain, rr, ok := matchDomain(name, domains); ok {
+		return domain, rr, nil
+	}
+
+	// 未匹配时刷新一次缓存，以便识别刚添加的域名
+	domains, err = z.list(ctx, true)
+	if err != nil {
+		return "", "", err
+	}
+	if domain, rr, ok := matchDomain(name, domains); ok {
+		return domain, rr, nil
+	}
+	return "", "", fmt.Errorf("%w: no domain in this Alibaba Cloud DNS account covers %s; add the zone to Alibaba Cloud DNS or check the credentials in use", ErrZoneNotFound, name)
+}
+
+// matchDomain 在 domains 中查找 name 的最长后缀
+func matchDomain(name string, domains []string) (string, string, bool) {
+	best := ""
+	for _, domain := range domains {
+		if (name == domain || strings.HasSuffix(name, "."+domain)) && len(domain) > len(best) {
+			best = domain
+		}
+	}
+	if best == "" {
+		return "", "", false
+	}
+	if name == best {
+		return best, "@", true
+	}
+	return best, strings.TrimSuffix(name, "."+best), true
+}
+
+// list 返回缓存的域名列表，缓存过期或 refresh 为 true 时重新查询
+func (z *zoneResolver) list(ctx context.Context, refresh bool) ([]string, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	now := time.Now
+	if z.now != nil {
+		now = z.now
+	}
+
+	age := now().Sub(z.fetchedAt)
+	if z.domains != nil && age < zoneCacheTTL && (!refresh || age < zoneMissRefreshInterval) {
+		return z.domains, nil
+	}
+
+	domains, err := z.describeDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	z.domains, z.fetchedAt = domains, now()
+	return domains, nil
+}
+
+// describeDomains 分页查询账号下的全部域名
+func (z *zoneResolver) describeDomains(ctx context.Context) ([]string, error) {
+	domains := []string{}
+	pageNumber := int64(1)
+
+	for {
+		request := &alidns.DescribeDomainsRequest{
+			PageNumber: tea.Int64(pageNumber),
+			PageSize:   tea.Int64(pageSizeRequest),
+		}
+
+		response, err := callWithRetry(ctx, "DescribeDomains", func(runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+			return z.client.DescribeDomainsWithOptions(request, runtime)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe domains: %w", err)
+		}
+
+		var page []*alidns.DescribeDomainsResponseBodyDomainsDomain
+		if response.Body.Domains != nil {
+			page = response.Body.Domains.Domain
+		}
+		for _, d := range page {
+			if d.DomainName != nil {
+				domains = append(domains, normalizeZone(*d.DomainName))
+			}
+		}
+
+		// 如果没有更多域名，退出循环
+		if len(page) == 0 || response.Body.TotalCount == nil || int64(len(domains)) >= *response.Body.TotalCount {
+			break
+		}
+		pageNumber++
+	}
+
+	return domains, nil
+}
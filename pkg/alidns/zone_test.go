@@ -0,0 +1,126 @@
+package alidns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	alidns "github.com/alibabacloud-go/alidns-20150109/v5/client"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDescribeDomainsFunc 按 pageSizeRequest 分页返回 names
+func newDescribeDomainsFunc(names *[]string, calls *int) func(*alidns.DescribeDomainsRequest, *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+	return func(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+		*calls++
+		start := int((*request.PageNumber - 1) * *request.PageSize)
+		end := start + int(*request.PageSize)
+		if end > len(*names) {
+			end = len(*names)
+		}
+		var page []*alidns.DescribeDomainsResponseBodyDomainsDomain
+		for _, name := range (*names)[start:end] {
+			page = append(page, &alidns.DescribeDomainsResponseBodyDomainsDomain{DomainName: tea.String(name)})
+		}
+		return &alidns.DescribeDomainsResponse{
+			Body: &alidns.DescribeDomainsResponseBody{
+				TotalCount: tea.Int64(int64(len(*names))),
+				Domains:    &alidns.DescribeDomainsResponseBodyDomains{Domain: page},
+			},
+		}, nil
+	}
+}
+
+func TestZoneResolver_ResolveDomain(t *testing.T) {
+	names := []string{"example.com", "dev.example.com", "中文.com"}
+	// 填充到需要多页查询
+	for i := 0; i < 150; i++ {
+		names = append(names, "filler"+string(rune('a'+i%26))+".net")
+	}
+	calls := 0
+	resolver := &zoneResolver{client: &MockAliDNSClient{DescribeDomainsFunc: newDescribeDomainsFunc(&names, &calls)}}
+
+	tests := []struct {
+		name         string
+		fqdn         string
+		expectDomain string
+		expectRR     string
+		expectError  bool
+	}{
+		{name: "registered zone", fqdn: "_acme-challenge.example.com.", expectDomain: "example.com", expectRR: "_acme-challenge"},
+		{name: "nested record", fqdn: "_acme-challenge.www.example.com.", expectDomain: "example.com", expectRR: "_acme-challenge.www"},
+		{name: "subdomain registered separately", fqdn: "_acme-challenge.www.dev.example.com.", expectDomain: "dev.example.com", expectRR: "_acme-challenge.www"},
+		{name: "apex", fqdn: "dev.example.com.", expectDomain: "dev.example.com", expectRR: "@"},
+		{name: "punycode", fqdn: "_acme-challenge.xn--fiq228c.com.", expectDomain: "中文.com", expectRR: "_acme-challenge"},
+		{name: "case insensitive", fqdn: "_acme-challenge.Example.COM.", expectDomain: "example.com", expectRR: "_acme-challenge"},
+		{name: "suffix is not a label boundary", fqdn: "_acme-challenge.notexample.com.", expectError: true},
+		{name: "not registered", fqdn: "_acme-challenge.example.org.", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain, rr, err := resolver.ResolveDomain(context.Background(), tt.fqdn)
+			if tt.expectError {
+				assert.ErrorContains(t, err, "no domain in this Alibaba Cloud DNS account covers")
+				assert.ErrorIs(t, err, ErrZoneNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectDomain, domain)
+			assert.Equal(t, tt.expectRR, rr)
+		})
+	}
+
+	// 153 个域名需要 2 页查询，之后都使用缓存（未匹配时距离上次查询不足最小刷新间隔）
+	assert.Equal(t, 2, calls)
+}
+
+func TestZoneResolver_Cache(t *testing.T) {
+	names := []string{"example.com"}
+	calls := 0
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver := &zoneResolver{
+		client: &MockAliDNSClient{DescribeDomainsFunc: newDescribeDomainsFunc(&names, &calls)},
+		now:    func() time.Time { return now },
+	}
+
+	_, _, err := resolver.ResolveDomain(context.Background(), "_acme-challenge.example.com.")
+	require.NoError(t, err)
+	_, _, err = resolver.ResolveDomain(context.Background(), "_acme-challenge.www.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls, "cached domain list should be reused")
+
+	// 新添加的域名：刚刷新过时不会立即重新查询
+	names = append(names, "example.org")
+	_, _, err = resolver.ResolveDomain(context.Background(), "_acme-challenge.example.org.")
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// 超过最小刷新间隔后，未匹配会触发刷新
+	now = now.Add(zoneMissRefreshInterval)
+	domain, _, err := resolver.ResolveDomain(context.Background(), "_acme-challenge.example.org.")
+	require.NoError(t, err)
+	assert.Equal(t, "example.org", domain)
+	assert.Equal(t, 2, calls)
+
+	// 缓存过期后重新查询
+	now = now.Add(zoneCacheTTL)
+	_, _, err = resolver.ResolveDomain(context.Background(), "_acme-challenge.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestZoneResolver_APIError(t *testing.T) {
+	resolver := &zoneResolver{client: &MockAliDNSClient{
+		DescribeDomainsFunc: func(request *alidns.DescribeDomainsRequest, runtime *util.RuntimeOptions) (*alidns.DescribeDomainsResponse, error) {
+			return nil, errors.New("describe API error")
+		},
+	}}
+
+	_, _, err := resolver.ResolveDomain(context.Background(), "_acme-challenge.example.com.")
+	assert.ErrorContains(t, err, "failed to describe domains")
+}